@@ -0,0 +1,95 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"reflect"
+)
+
+// Adapt allows funcs registered with a typed chain to have a signature
+// that is compatible with the chain's type without being convertible to
+// it: each of the chain's parameter types need only be assignable to the
+// corresponding parameter of the func (so a func(io.Writer) can be
+// registered with a chain of func(*os.File)) and each of the func's
+// results assignable to the chain's. Such funcs are wrapped in an adapter
+// of the chain's type. Funcs which are already convertible are registered
+// as is and those which aren't compatible at all are rejected as usual.
+func Adapt() RegisterOption {
+	return func(r *registration) {
+		r.adapt = true
+	}
+}
+
+// replaces any funcs that can be adapted to the chain's type with adapters
+func (r *registration) adaptAll(ftype reflect.Type, fn []interface{}) []interface{} {
+	if r == nil || !r.adapt || ftype == nil {
+		return fn
+	}
+	for i, f := range fn {
+		if val := reflect.ValueOf(f); val.Kind() == reflect.Func && adaptable(val.Type(), ftype) {
+			fn[i] = adapter(val, ftype)
+		}
+	}
+	return fn
+}
+
+func adaptable(T, ftype reflect.Type) bool {
+	if T.ConvertibleTo(ftype) || T.NumIn() != ftype.NumIn() ||
+		T.NumOut() != ftype.NumOut() || T.IsVariadic() != ftype.IsVariadic() {
+		return false
+	}
+	for i := 0; i < T.NumIn(); i++ {
+		if !ftype.In(i).AssignableTo(T.In(i)) {
+			return false
+		}
+	}
+	for i := 0; i < T.NumOut(); i++ {
+		if !T.Out(i).AssignableTo(ftype.Out(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+func adapter(val reflect.Value, ftype reflect.Type) interface{} {
+	call := val.Call
+	if ftype.IsVariadic() {
+		call = val.CallSlice
+	}
+	return reflect.MakeFunc(ftype, func(in []reflect.Value) []reflect.Value {
+		// reflect converts the arguments to the func's parameter types
+		// but results must be converted to the chain's explicitly. Nil
+		// pointers (etc) become nil interfaces, not interfaces holding a
+		// nil, so that a func returning a nil *MyError hasn't failed.
+		out := call(in)
+		for i, v := range out {
+			if T := ftype.Out(i); v.Type() != T {
+				r := reflect.New(T).Elem()
+				if !isNil(v) || T.Kind() != reflect.Interface {
+					r.Set(v)
+				}
+				out[i] = r
+			}
+		}
+		return out
+	}).Interface()
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package chain_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+type closeError struct{}
+
+func (closeError) Error() string { return "close" }
+
+func TestAdapt(t *testing.T) {
+	c := chain.NewTyped(func(*bytes.Buffer) error { return nil })
+	write := func(w io.Writer) *closeError {
+		fmt.Fprint(w, "adapted")
+		return nil
+	}
+	if _, err := c.Register(write); err == nil {
+		t.Fatal("expected incompatible func to be rejected without Adapt")
+	}
+	if _, err := c.Register(write, chain.Adapt()); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := c.Run(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "adapted" {
+		t.Fatalf("unexpected output %q", buf.String())
+	}
+}
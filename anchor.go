@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"errors"
+)
+
+var (
+	ErrFirstAlreadyClaimed = errors.New("first node of the chain has already been claimed")
+	ErrLastAlreadyClaimed  = errors.New("last node of the chain has already been claimed")
+)
+
+// AnchorPolicy decides what First() and Last() do when the true first or
+// last node of a chain has already been claimed, see WithAnchors.
+type AnchorPolicy int
+
+const (
+	// First() and Last() always create a new node at the very beginning or
+	// end of the chain, so that each call displaces the previous one.
+	AnchorNone AnchorPolicy = iota
+	// The first call to First() or Last() claims an anchor node, later
+	// calls return ErrFirstAlreadyClaimed or ErrLastAlreadyClaimed.
+	AnchorExclusive
+	// Later calls to First() or Last() register their funcs with the
+	// existing anchor node, to run concurrently with those already there.
+	AnchorJoin
+)
+
+// WithAnchors makes First() and Last() register against dedicated anchor
+// nodes which stay at the very beginning and end of the chain, so that
+// competing packages cannot silently leapfrog each other. With anchors
+// nothing can be placed in front of the first anchor or behind the last:
+// Before() or SpliceBefore() on the first anchor and After(), RegisterSeq()
+// or SpliceAfter() on the last return the errors above, as do the Builder
+// and Handle forms, and nodes created by Order() go in front of the last
+// anchor. The default is AnchorNone.
+func WithAnchors(p AnchorPolicy) Option {
+	return func(o *options) {
+		o.anchors = p
+	}
+}
+
+type anchorKind uint8
+
+const (
+	anchorFirst anchorKind = iota + 1
+	anchorLast
+)
+
+// finds or creates the node First() registers with, must be called with the
+// chain locked.
+func (cn *chainNode) firstNode() (*chainNode, error) {
+	first := cn.getFirst()
+	switch {
+	case cn.opts.anchors == AnchorNone:
+		return first.insertBefore(), nil
+	case first.anchor != anchorFirst:
+		n := first.insertBefore()
+		n.anchor = anchorFirst
+		return n, nil
+	case cn.opts.anchors == AnchorJoin:
+		return first, nil
+	}
+	return nil, ErrFirstAlreadyClaimed
+}
+
+// finds or creates the node Last() registers with, must be called with the
+// chain locked.
+func (cn *chainNode) lastNode() (*chainNode, error) {
+	last := cn.getLast()
+	switch {
+	case cn.opts.anchors == AnchorNone:
+		return last.insertAfter(), nil
+	case last.anchor != anchorLast:
+		n := last.insertAfter()
+		n.anchor = anchorLast
+		return n, nil
+	case cn.opts.anchors == AnchorJoin:
+		return last, nil
+	}
+	return nil, ErrLastAlreadyClaimed
+}
+
+// reports an error if inserting a node before (or after) the receiver would
+// leapfrog an anchor.
+func (cn *chainNode) checkAnchor(before bool) error {
+	switch {
+	case before && cn.anchor == anchorFirst:
+		return ErrFirstAlreadyClaimed
+	case !before && cn.anchor == anchorLast:
+		return ErrLastAlreadyClaimed
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package chain_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestAnchorExclusive(t *testing.T) {
+	var ran []string
+	record := func(name string) func() {
+		return func() { ran = append(ran, name) }
+	}
+	c := chain.New(chain.WithWorkers(1), chain.WithAnchors(chain.AnchorExclusive))
+	p, err := c.Register(record("middle"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := p.First(record("first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, err := p.Last(record("last"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.First(record("usurper")); !errors.Is(err, chain.ErrFirstAlreadyClaimed) {
+		t.Errorf("second First() returned %v", err)
+	}
+	if _, err = p.Last(record("usurper")); !errors.Is(err, chain.ErrLastAlreadyClaimed) {
+		t.Errorf("second Last() returned %v", err)
+	}
+	if _, err = first.Before(record("usurper")); !errors.Is(err, chain.ErrFirstAlreadyClaimed) {
+		t.Errorf("Before() the first anchor returned %v", err)
+	}
+	if _, err = last.After(record("usurper")); !errors.Is(err, chain.ErrLastAlreadyClaimed) {
+		t.Errorf("After() the last anchor returned %v", err)
+	}
+	if _, err = c.Register(record("ordered"), chain.Order(chain.OrderLast)); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"first", "middle", "ordered", "last"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+}
+
+func TestAnchorJoin(t *testing.T) {
+	c := chain.New(chain.WithAnchors(chain.AnchorJoin))
+	p, err := c.Register(func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := p.First(func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := p.First(func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Error("second First() did not join the first anchor")
+	}
+	if n := len(c.Nodes()); n != 2 {
+		t.Errorf("chain has %d nodes, want 2", n)
+	}
+}
+
+func TestAnchorBatch(t *testing.T) {
+	c := chain.New(chain.WithAnchors(chain.AnchorExclusive))
+	p, err := c.Register(func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := p.First(func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, err := p.Last(func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		stage func(chain.Builder)
+		want  error
+	}{
+		"First":  {func(b chain.Builder) { b.First(func() {}) }, chain.ErrFirstAlreadyClaimed},
+		"Last":   {func(b chain.Builder) { b.Last(func() {}) }, chain.ErrLastAlreadyClaimed},
+		"Before": {func(b chain.Builder) { b.Before(first, func() {}) }, chain.ErrFirstAlreadyClaimed},
+		"After":  {func(b chain.Builder) { b.After(last, func() {}) }, chain.ErrLastAlreadyClaimed},
+	} {
+		err = c.Batch(func(b chain.Builder) error {
+			tc.stage(b)
+			return nil
+		})
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s returned %v, want %v", name, err, tc.want)
+		}
+	}
+	if n := len(c.Nodes()); n != 3 {
+		t.Errorf("chain has %d nodes, want 3", n)
+	}
+}
+
+func TestAnchorHandle(t *testing.T) {
+	var ran []string
+	record := func(name string) func() {
+		return func() { ran = append(ran, name) }
+	}
+	c := chain.New(chain.WithWorkers(1), chain.WithAnchors(chain.AnchorExclusive))
+	p, err := c.Register(record("middle"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var first, last chain.Handle
+	if _, err = p.First(record("first"), record("first too"), chain.Capture(&first)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.Last(record("last"), record("last too"), chain.Capture(&last)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = chain.Before(first, record("usurper")); !errors.Is(err, chain.ErrFirstAlreadyClaimed) {
+		t.Errorf("Before() a func in the first anchor returned %v", err)
+	}
+	if _, err = chain.After(last, record("usurper")); !errors.Is(err, chain.ErrLastAlreadyClaimed) {
+		t.Errorf("After() a func in the last anchor returned %v", err)
+	}
+
+	// splitting an anchor node keeps the captured funcs at the ends
+	if _, err = chain.After(first, record("after first")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = chain.Before(last, record("before last")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Register(record("ordered"), chain.Order(chain.OrderLast)); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"first", "first too", "after first", "middle", "last too", "before last", "ordered", "last"}
+	if !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+	if _, err = p.First(record("usurper")); !errors.Is(err, chain.ErrFirstAlreadyClaimed) {
+		t.Errorf("First() after splitting the anchor returned %v", err)
+	}
+}
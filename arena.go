@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+// arena hands out the entries and nodes of a chain from packed chunks,
+// so that a chain with tens of thousands of registrations makes a few
+// hundred allocations rather than one for every func. Chunks are never
+// moved so their elements can be referred to by pointer as usual (by nodes
+// and Handles; snapshots take copies of the entries). A chunk is only
+// collected once nothing refers to any of its elements, so entries removed
+// from the chain are zeroed by free() to let go of their funcs. Protected
+// by the chain lock.
+//
+// The arena only addresses the allocation cost of large chains. Nodes are
+// still linked to each other by pointer rather than packed and addressed
+// by index, as Predicates are *chainNode values that callers keep across
+// registrations and compare with each other, so a node has to stay where
+// it is for the life of the chain. Walking the chain therefore still
+// chases pointers: getFirst(), getLast(), Position(), lookup() and
+// checkName() are linear in the number of nodes.
+type arena struct {
+	entries []entry
+	nodes   []chainNode
+}
+
+const (
+	minArenaChunk = 8
+	maxArenaChunk = 1024
+)
+
+// chunks start small so that small chains don't waste space, and double in
+// size as the chain grows
+func arenaChunk(prev int) int {
+	switch {
+	case prev < minArenaChunk:
+		return minArenaChunk
+	case prev >= maxArenaChunk:
+		return maxArenaChunk
+	}
+	return prev * 2
+}
+
+func (a *arena) entry() *entry {
+	if a == nil {
+		return &entry{}
+	}
+	if len(a.entries) == cap(a.entries) {
+		a.entries = make([]entry, 0, arenaChunk(cap(a.entries)))
+	}
+	a.entries = a.entries[:len(a.entries)+1]
+	return &a.entries[len(a.entries)-1]
+}
+
+func (a *arena) node() *chainNode {
+	if a == nil {
+		return &chainNode{}
+	}
+	if len(a.nodes) == cap(a.nodes) {
+		a.nodes = make([]chainNode, 0, arenaChunk(cap(a.nodes)))
+	}
+	a.nodes = a.nodes[:len(a.nodes)+1]
+	return &a.nodes[len(a.nodes)-1]
+}
+
+// releases an entry removed from the chain. Its slot is not reused, a
+// Handle to it simply no longer finds it in any node.
+func (a *arena) free(e *entry) {
+	*e = entry{}
+}
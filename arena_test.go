@@ -0,0 +1,115 @@
+package chain_test
+
+import (
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestLargeChain(t *testing.T) {
+	var calls int64
+	fn := func() { atomic.AddInt64(&calls, 1) }
+	c := chain.New()
+	p := c.Head()
+	for i := 0; i < 50000; i++ {
+		if i%1000 == 999 {
+			p, _ = p.After(fn)
+			continue
+		}
+		if _, err := p.Register(fn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clone := c.Clone()
+	if _, err := clone.Register(fn); err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 50000 || clone.Len() != 50001 {
+		t.Fatalf("chain has %d funcs and its clone %d", c.Len(), clone.Len())
+	}
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := clone.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 100001 {
+		t.Errorf("%d funcs called, want 100001", calls)
+	}
+}
+
+func BenchmarkRegister(b *testing.B) {
+	fn := func() {}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p := chain.New().Head()
+		for j := 0; j < 1000; j++ {
+			p.Register(fn)
+		}
+	}
+}
+
+// returns a func holding on to an object and a channel closed once the
+// object has been collected
+func collectable() (func(), <-chan struct{}) {
+	collected := make(chan struct{})
+	obj := new([64]byte)
+	runtime.SetFinalizer(obj, func(*[64]byte) { close(collected) })
+	return func() { _ = obj[0] }, collected
+}
+
+func awaitCollected(t *testing.T, collected <-chan struct{}, what string) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		runtime.GC()
+		select {
+		case <-collected:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	t.Errorf("%s was never released", what)
+}
+
+func TestArenaFree(t *testing.T) {
+	c := chain.New()
+	keep := func() {}
+	p, err := c.Register(keep)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expiring, expired := collectable()
+	if _, err = p.Register(expiring, chain.MaxRuns(1)); err != nil {
+		t.Fatal(err)
+	}
+	expiring = nil
+	if err = c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	// the expired func is pruned by the next run
+	if err = c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	awaitCollected(t, expired, "expired func")
+
+	staged, abandoned := collectable()
+	failure := errors.New("failure")
+	if err = c.Batch(func(b chain.Builder) error {
+		b.After(p, staged)
+		return failure
+	}); err != failure {
+		t.Fatalf("expected %v, got %v", failure, err)
+	}
+	staged = nil
+	awaitCollected(t, abandoned, "func of an abandoned batch")
+
+	if c.Len() != 1 {
+		t.Fatalf("chain has %d funcs, want 1", c.Len())
+	}
+	runtime.KeepAlive(c)
+}
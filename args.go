@@ -0,0 +1,279 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"fmt"
+	"reflect"
+)
+
+func (cn *chainNode) SetArgAdapter(fn func([]interface{}) ([]interface{}, error)) error {
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	if err := cn.checkSealed(); err != nil {
+		return err
+	}
+	cn.opts.argAdapter = fn
+	cn.opts.cache.invalidate()
+	return nil
+}
+
+// argSet holds the arguments of a run at each stage of their preparation
+type argSet struct {
+	// as passed to the run, after any adapter has been applied
+	run []interface{}
+	// preceded by any bound arguments, as seen by filters
+	args []interface{}
+	// preceded by any injected context and fitted to the chain's type, as
+	// passed to Runners
+	raw []interface{}
+	// raw reflected, as passed to funcs
+	vals []reflect.Value
+	// the positions of any placeholders in raw
+	templ []int
+}
+
+// prepares the arguments passed to a run for its funcs
+func (e *Execution) prepare(args []interface{}) (*argSet, error) {
+	s := e.snap
+	if adapt := s.opts.argAdapter; adapt != nil {
+		var err error
+		if args, err = adapt(args); err != nil {
+			return nil, err
+		}
+	}
+	a := &argSet{run: args}
+	if len(s.opts.boundArgs) > 0 {
+		args = append(append(make([]interface{}, 0, len(s.opts.boundArgs)+len(args)), s.opts.boundArgs...), args...)
+	}
+	a.args = args
+	in := args
+	if e.ctx != nil && takesContext(s.ftype) {
+		in = append([]interface{}{e.ctx}, args...)
+	}
+	// NB: every func is handed the same argument slice, it must have no
+	// spare capacity so that CallProxy implementations appending to it
+	// don't step on each other.
+	if s.ftype != nil && s.opts.argPolicy != ArgsStrict {
+		in = s.opts.argPolicy.fitArgs(s.ftype, in)
+	}
+	a.raw = in
+	a.templ = placeholders(in)
+	var err error
+	if a.vals, err = convertArgs(s.ftype, in); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// returns the arguments a call is to be passed
+func (e *Execution) argsFor(c call) *argSet {
+	if c.sets != nil {
+		return c.sets[c.index]
+	}
+	return e.args
+}
+
+// convertArgs reflects the arguments passed to a run and, for typed
+// chains, checks them against the chain's func type so that mismatches are
+// reported before anything runs rather than as a panic inside reflect.
+// Untyped nil arguments are replaced with the zero value of the
+// corresponding parameter.
+func convertArgs(ftype reflect.Type, args []interface{}) ([]reflect.Value, error) {
+	vals := make([]reflect.Value, len(args))
+	if ftype == nil {
+		for i, v := range args {
+			vals[i] = reflect.ValueOf(v)
+		}
+		return vals, nil
+	}
+
+	fixed := ftype.NumIn()
+	if ftype.IsVariadic() {
+		fixed--
+		if len(args) < fixed {
+			return nil, fmt.Errorf("%v requires at least %d argument(s), got %d", ftype, fixed, len(args))
+		}
+	} else if len(args) != fixed {
+		return nil, fmt.Errorf("%v requires %d argument(s), got %d", ftype, fixed, len(args))
+	}
+
+	for i, v := range args {
+		T := paramType(ftype, i)
+		// placeholders are converted as they are substituted for each call
+		if _, ok := v.(Placeholder); ok {
+			vals[i] = reflect.Zero(T)
+			continue
+		}
+		val, err := convertArg(T, v)
+		if err != nil {
+			return nil, &ArgumentError{Index: i, Err: err}
+		}
+		vals[i] = val
+	}
+	return vals, nil
+}
+
+// returns the type of the ith argument passed to a func of type T, which
+// must have at least i+1 parameters or be variadic
+func paramType(T reflect.Type, i int) reflect.Type {
+	fixed := T.NumIn()
+	if T.IsVariadic() {
+		fixed--
+	}
+	if i < fixed {
+		return T.In(i)
+	}
+	return T.In(fixed).Elem()
+}
+
+func convertArg(T reflect.Type, v interface{}) (reflect.Value, error) {
+	val := reflect.ValueOf(v)
+	switch {
+	case !val.IsValid():
+		switch T.Kind() {
+		case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+			return reflect.Zero(T), nil
+		}
+		return val, fmt.Errorf("nil cannot be used as %v", T)
+	case !val.Type().AssignableTo(T):
+		return val, fmt.Errorf("%v cannot be used as %v", val.Type(), T)
+	}
+	return val, nil
+}
+
+// Bind returns a func which calls fn with preArgs followed by whatever
+// arguments it is itself called with, so that its type is that of fn
+// without the leading parameters. This makes it possible to register a
+// func with a typed chain whose type matches only fn's trailing
+// parameters:
+//
+//	c := chain.NewTyped(func(context.Context) error(nil))
+//	c.Register(chain.Bind(db.Open, logger, cfg))
+//
+// If fn isn't a func or preArgs don't fit its leading parameters, the
+// value returned by Bind is rejected by Register() (and friends) with
+// the reason.
+func Bind(fn interface{}, preArgs ...interface{}) interface{} {
+	val := reflect.ValueOf(fn)
+	if val.Kind() != reflect.Func {
+		return &bindError{ErrChainNotFunc}
+	}
+	T := val.Type()
+	fixed := T.NumIn()
+	if T.IsVariadic() {
+		fixed--
+	}
+	if len(preArgs) > fixed {
+		return &bindError{fmt.Errorf("%v cannot have %d argument(s) bound", T, len(preArgs))}
+	}
+	bound := make([]reflect.Value, len(preArgs))
+	for i, v := range preArgs {
+		var err error
+		if bound[i], err = convertArg(T.In(i), v); err != nil {
+			return &bindError{&ArgumentError{Index: i, Err: err}}
+		}
+	}
+
+	in := make([]reflect.Type, T.NumIn()-len(bound))
+	for i := range in {
+		in[i] = T.In(i + len(bound))
+	}
+	out := make([]reflect.Type, T.NumOut())
+	for i := range out {
+		out[i] = T.Out(i)
+	}
+	call := val.Call
+	if T.IsVariadic() {
+		call = val.CallSlice
+	}
+	return reflect.MakeFunc(reflect.FuncOf(in, out, T.IsVariadic()), func(args []reflect.Value) []reflect.Value {
+		return call(append(append(make([]reflect.Value, 0, len(bound)+len(args)), bound...), args...))
+	}).Interface()
+}
+
+// returned by Bind() in place of a func it couldn't create
+type bindError struct {
+	err error
+}
+
+// ArgPolicy determines what a run does when it is passed a different
+// number of arguments than the chain's funcs take (see WithArgPolicy).
+type ArgPolicy int
+
+const (
+	// ArgsStrict fails typed chain runs passed the wrong number of
+	// arguments before anything runs. Funcs of untyped chains fail
+	// individually, as if they had panicked.
+	ArgsStrict ArgPolicy = 0
+	// ArgsTruncate drops surplus trailing arguments.
+	ArgsTruncate ArgPolicy = 1
+	// ArgsPad supplies the zero value for missing trailing arguments.
+	ArgsPad ArgPolicy = 2
+	// ArgsLenient both truncates and pads.
+	ArgsLenient = ArgsTruncate | ArgsPad
+)
+
+// WithArgPolicy sets how runs deal with surplus or missing arguments. For
+// typed chains the policy is applied once per run, for untyped chains it
+// is applied separately for each func.
+func WithArgPolicy(policy ArgPolicy) Option {
+	return func(o *options) {
+		o.argPolicy = policy
+	}
+}
+
+// returns the number of arguments a func of type T should be passed when
+// a run is passed n
+func (policy ArgPolicy) fit(T reflect.Type, n int) int {
+	fixed := T.NumIn()
+	if T.IsVariadic() {
+		fixed--
+		if n >= fixed {
+			return n
+		}
+	}
+	if (n > fixed && policy&ArgsTruncate != 0) || (n < fixed && policy&ArgsPad != 0) {
+		return fixed
+	}
+	return n
+}
+
+// fits reflected arguments to a func type according to the policy
+func (policy ArgPolicy) fitValues(T reflect.Type, args []reflect.Value) []reflect.Value {
+	n := policy.fit(T, len(args))
+	if n <= len(args) {
+		return args[:n:n]
+	}
+	padded := make([]reflect.Value, n)
+	copy(padded, args)
+	for i := len(args); i < n; i++ {
+		padded[i] = reflect.Zero(T.In(i))
+	}
+	return padded
+}
+
+// as fitValues() but for the unconverted arguments passed to a typed chain
+func (policy ArgPolicy) fitArgs(T reflect.Type, args []interface{}) []interface{} {
+	n := policy.fit(T, len(args))
+	if n <= len(args) {
+		return args[:n:n]
+	}
+	padded := make([]interface{}, n)
+	copy(padded, args)
+	for i := len(args); i < n; i++ {
+		padded[i] = reflect.Zero(T.In(i)).Interface()
+	}
+	return padded
+}
@@ -0,0 +1,99 @@
+package chain_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestArgAdapter(t *testing.T) {
+	var got string
+	c := chain.NewTyped(func(string) {})
+	c.Register(func(s string) { got = s })
+	c.SetArgAdapter(func(args []interface{}) ([]interface{}, error) {
+		if len(args) == 0 {
+			return []interface{}{"default"}, nil
+		}
+		return args, nil
+	})
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got != "default" {
+		t.Fatalf("adapter not applied, got %q", got)
+	}
+
+	bad := errors.New("bad args")
+	c.SetArgAdapter(func([]interface{}) ([]interface{}, error) { return nil, bad })
+	got = ""
+	if err := c.Run("x"); !errors.Is(err, bad) || got != "" {
+		t.Fatalf("expected adapter error and no run, got %v (%q)", err, got)
+	}
+}
+
+func TestBoundArgs(t *testing.T) {
+	var got []string
+	c := chain.NewTyped(func(string, string) {}, chain.WithBoundArgs("log"))
+	c.Register(func(a, b string) { got = append(got, a, b) })
+	if err := c.Run("run"); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "log" || got[1] != "run" {
+		t.Fatalf("unexpected args %v", got)
+	}
+}
+
+func TestBind(t *testing.T) {
+	var got []interface{}
+	c := chain.NewTyped(func(string) error { return nil })
+	_, err := c.Register(chain.Bind(func(n int, prefix, s string) error {
+		got = append(got, n, prefix, s)
+		return nil
+	}, 1, "x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Run("y"); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0] != 1 || got[1] != "x" || got[2] != "y" {
+		t.Fatalf("unexpected args %v", got)
+	}
+
+	var ae *chain.ArgumentError
+	if _, err = c.Register(chain.Bind(func(int, string) error { return nil }, "1")); !errors.As(err, &ae) || ae.Index != 0 {
+		t.Fatalf("expected bad bound argument to be rejected, got %v", err)
+	}
+	if _, err = c.Register(chain.Bind(func(int) error { return nil }, 1)); err == nil {
+		t.Fatal("expected mismatched func to be rejected")
+	}
+}
+
+func TestArgPolicy(t *testing.T) {
+	var got []int
+	c := chain.NewTyped(func(int, int) {}, chain.WithArgPolicy(chain.ArgsLenient))
+	c.Register(func(a, b int) { got = append(got, a, b) })
+	if err := c.Run(1, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Run(4); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 || got[0] != 1 || got[1] != 2 || got[2] != 4 || got[3] != 0 {
+		t.Fatalf("unexpected args %v", got)
+	}
+
+	got = nil
+	u := chain.New(chain.WithArgPolicy(chain.ArgsTruncate))
+	u.Register(func(a int) { got = append(got, a) }, func() { got = append(got, -1) })
+	if err := u.Run(5, 6); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("unexpected args %v", got)
+	}
+	if err := chain.NewTyped(func(int) {}).Run(1, 2); err == nil {
+		t.Fatal("expected surplus argument to fail a strict run")
+	}
+}
@@ -0,0 +1,189 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+// Builder stages registrations for Root.Batch(). Its methods mirror those
+// of Predicate, taking the node to operate on (nil meaning the chain Batch
+// was called on) and returning the affected node, which may be passed to
+// later Builder calls. Nothing is visible to the rest of the program until
+// the batch is applied. As the chain is locked for the duration of the
+// batch, the methods of Root and Predicate must not be called until Batch
+// returns.
+//
+// Once any call fails the remaining calls do nothing and the batch is
+// abandoned; the error is available from Err() and is returned by Batch.
+type Builder interface {
+	Register(Predicate, ...interface{}) Predicate
+	Before(Predicate, ...interface{}) Predicate
+	After(Predicate, ...interface{}) Predicate
+	First(...interface{}) Predicate
+	Last(...interface{}) Predicate
+	Err() error
+}
+
+type builder struct {
+	cn  *chainNode
+	err error
+}
+
+// the state of a single node saved so that a batch can be rolled back
+type nodeState struct {
+	n        *chainNode
+	funcs    []*entry
+	weighted bool
+	weight   int
+}
+
+func (cn *chainNode) Batch(fn func(Builder) error) error {
+	defer cn.notify()
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	if err := cn.checkSealed(); err != nil {
+		return err
+	}
+
+	var saved []nodeState
+	for n := cn.getFirst(); n != nil; n = n.getNext() {
+		saved = append(saved, nodeState{
+			n:        n,
+			funcs:    append([]*entry(nil), n.funcs...),
+			weighted: n.weighted,
+			weight:   n.weight,
+		})
+	}
+	pending := len(cn.opts.pending)
+
+	// a panic in fn (or runtime.Goexit) must not leave the chain half
+	// built either, so the rollback is deferred
+	applied := false
+	defer func() {
+		if !applied {
+			cn.rollback(saved, pending)
+		}
+	}()
+	b := &builder{cn: cn}
+	err := fn(b)
+	if err == nil {
+		err = b.err
+	}
+	applied = err == nil
+	return err
+}
+
+// restores the nodes saved at the start of a batch, releasing the entries
+// of any funcs it staged, must be called with the chain locked.
+func (cn *chainNode) rollback(saved []nodeState, pending int) {
+	kept := make(map[*entry]bool)
+	for _, s := range saved {
+		for _, e := range s.funcs {
+			kept[e] = true
+		}
+	}
+	for n := cn.getFirst(); n != nil; n = n.getNext() {
+		for _, e := range n.funcs {
+			if !kept[e] {
+				cn.opts.arena.free(e)
+			}
+		}
+	}
+	for i, s := range saved {
+		s.n.before, s.n.after = nil, nil
+		if i > 0 {
+			s.n.before = saved[i-1].n
+			saved[i-1].n.after = s.n
+		}
+		s.n.funcs, s.n.weighted, s.n.weight = s.funcs, s.weighted, s.weight
+	}
+	cn.opts.pending = cn.opts.pending[:pending]
+	cn.opts.cache.invalidate()
+}
+
+func (b *builder) Err() error {
+	return b.err
+}
+
+func (b *builder) node(p Predicate) *chainNode {
+	if p == nil {
+		return b.cn
+	}
+	n, ok := p.(*chainNode)
+	if !ok || n.lock != b.cn.lock {
+		b.err = ErrChainInvalidType
+		return nil
+	}
+	return n
+}
+
+// stages funcs with the node returned by place, or with the node itself
+// (as Register does) if place is nil
+func (b *builder) stage(p Predicate, fn []interface{}, place func(*chainNode) (*chainNode, error)) Predicate {
+	site := callSite()
+	if b.err != nil {
+		return p
+	}
+	n := b.node(p)
+	if n == nil {
+		b.err = siteError(site, b.err)
+		return p
+	}
+	funcs, reg, err := validateAll(n, fn)
+	if err != nil {
+		b.err = siteError(site, err)
+		return p
+	}
+	switch {
+	case reg.ordered() && place != nil:
+		b.err = siteError(site, ErrOrderConflict)
+		return p
+	case reg.ordered():
+		n = n.ordered(*reg.order)
+	case place != nil:
+		if n, err = place(n); err != nil {
+			b.err = siteError(site, err)
+			return p
+		}
+	}
+	n.addAll(funcs, site, reg)
+	return n
+}
+
+func (b *builder) Register(p Predicate, fn ...interface{}) Predicate {
+	return b.stage(p, fn, nil)
+}
+
+func (b *builder) Before(p Predicate, fn ...interface{}) Predicate {
+	return b.stage(p, fn, func(n *chainNode) (*chainNode, error) {
+		if err := n.checkAnchor(true); err != nil {
+			return nil, err
+		}
+		return n.insertBefore(), nil
+	})
+}
+
+func (b *builder) After(p Predicate, fn ...interface{}) Predicate {
+	return b.stage(p, fn, func(n *chainNode) (*chainNode, error) {
+		if err := n.checkAnchor(false); err != nil {
+			return nil, err
+		}
+		return n.insertAfter(), nil
+	})
+}
+
+func (b *builder) First(fn ...interface{}) Predicate {
+	return b.stage(nil, fn, (*chainNode).firstNode)
+}
+
+func (b *builder) Last(fn ...interface{}) Predicate {
+	return b.stage(nil, fn, (*chainNode).lastNode)
+}
@@ -0,0 +1,85 @@
+package chain_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestBatch(t *testing.T) {
+	c := chain.NewTyped(TestIntFunc(nil))
+	if _, err := c.Register(func(int) {}); err != nil {
+		t.Fatal(err)
+	}
+	events := 0
+	c.OnChange(func(chain.Event) { events++ })
+
+	err := c.Batch(func(b chain.Builder) error {
+		p := b.After(nil, func(int) {})
+		b.Before(p, func(int) {})
+		b.Last(func(string) {})
+		b.Register(p, func(int) {})
+		return nil
+	})
+	var regErr *chain.RegistrationError
+	if !errors.As(err, &regErr) {
+		t.Fatalf("expected a registration error, got %v", err)
+	}
+	n := 0
+	c.Walk(func(chain.Predicate, []chain.FuncInfo) error {
+		n++
+		return nil
+	})
+	if l := c.Snapshot().Len(); l != 1 || n != 1 || events != 0 {
+		t.Fatalf("failed batch was not rolled back: %d funcs in %d nodes, %d events", l, n, events)
+	}
+
+	abort := errors.New("abort")
+	if err = c.Batch(func(b chain.Builder) error {
+		b.First(func(int) {})
+		return abort
+	}); err != abort {
+		t.Fatalf("expected %v, got %v", abort, err)
+	}
+
+	var ran []int
+	if err = c.Batch(func(b chain.Builder) error {
+		p := b.After(nil, func(i int) { ran = append(ran, 2) })
+		b.Before(p, func(i int) { ran = append(ran, 1) })
+		b.After(p, func(i int) { ran = append(ran, 3) })
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Run(0); len(ran) != 3 || ran[0] != 1 || ran[1] != 2 || ran[2] != 3 || events == 0 {
+		t.Fatalf("unexpected calls %v after batch (%d events)", ran, events)
+	}
+}
+
+func TestBatchPanic(t *testing.T) {
+	c := chain.New()
+	p, err := c.Register(func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Fatalf("recovered %v, want the batch's panic", r)
+			}
+		}()
+		c.Batch(func(b chain.Builder) error {
+			b.After(p, func() {})
+			b.First(func() {})
+			panic("boom")
+		})
+	}()
+	if l, n := c.Len(), len(c.Nodes()); l != 1 || n != 1 {
+		t.Fatalf("chain has %d funcs in %d nodes after a panicking batch", l, n)
+	}
+	// the chain is still usable
+	if _, err = p.After(func() {}); err != nil {
+		t.Fatal(err)
+	}
+}
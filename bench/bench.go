@@ -0,0 +1,275 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+// Package bench builds synthetic call chains of a given shape and drives
+// them, either under go test -bench to measure the scheduler's overhead
+// per func or concurrently as a load test. For example
+//
+//	func BenchmarkStartup(b *testing.B) {
+//		bench.Sweep(b,
+//			bench.Shape{Nodes: 60, Funcs: 10},
+//			bench.Shape{Nodes: 60, Funcs: 10, Signature: bench.Typed},
+//		)
+//	}
+package bench
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jsipprell/go-chain"
+)
+
+// Signature is the kind of func a synthetic chain is built from.
+type Signature int
+
+const (
+	// Plain funcs take nothing and return nothing, which runs call
+	// without reflection.
+	Plain Signature = iota
+	// Args funcs are registered with an untyped chain and take an int
+	// and a string.
+	Args
+	// Typed funcs are registered with a typed chain and take an int and
+	// return an error.
+	Typed
+	// Context funcs take a context.Context and return an error, chains of
+	// them are run with RunContext().
+	Context
+)
+
+func (s Signature) String() string {
+	switch s {
+	case Plain:
+		return "plain"
+	case Args:
+		return "args"
+	case Typed:
+		return "typed"
+	case Context:
+		return "context"
+	}
+	return "Signature(?)"
+}
+
+// Shape describes a synthetic chain of Nodes nodes of Funcs funcs each.
+type Shape struct {
+	Nodes     int
+	Funcs     int
+	Signature Signature
+	// Work, if positive, is how long each func spins for to simulate the
+	// cost of real funcs.
+	Work time.Duration
+	// Options are passed to the chain's constructor.
+	Options []chain.Option
+}
+
+func (s Shape) String() string {
+	name := fmt.Sprintf("%dx%d/%v", s.Nodes, s.Funcs, s.Signature)
+	if s.Work > 0 {
+		name += "/" + s.Work.String()
+	}
+	return name
+}
+
+// Chain is a synthetic chain built by Build.
+type Chain struct {
+	chain.Root
+	Shape Shape
+	args  []interface{}
+	work  func()
+}
+
+// Build builds a chain of the given shape.
+func Build(s Shape) *Chain {
+	c := &Chain{Shape: s, work: spin(s.Work)}
+	work := c.work
+	var fn interface{}
+	switch s.Signature {
+	case Args:
+		c.Root = chain.New(s.Options...)
+		c.args = []interface{}{1, "x"}
+		fn = func(int, string) { work() }
+	case Typed:
+		c.Root = chain.NewTyped((func(int) error)(nil), s.Options...)
+		c.args = []interface{}{1}
+		fn = func(int) error { work(); return nil }
+	case Context:
+		c.Root = chain.New(s.Options...)
+		fn = func(context.Context) error { work(); return nil }
+	default:
+		c.Root = chain.New(s.Options...)
+		fn = func() { work() }
+	}
+	funcs := make([]interface{}, s.Funcs)
+	for i := range funcs {
+		funcs[i] = fn
+	}
+	p := c.Head()
+	for i := 0; i < s.Nodes; i++ {
+		var err error
+		if i == 0 {
+			_, err = p.Register(funcs...)
+		} else {
+			p, err = p.After(funcs...)
+		}
+		if err != nil {
+			panic(fmt.Sprintf("bench: %v", err))
+		}
+	}
+	return c
+}
+
+// Run runs the chain once with arguments suitable for its signature.
+func (c *Chain) Run() error {
+	if c.Shape.Signature == Context {
+		return c.RunContext(context.Background(), c.args...)
+	}
+	return c.Root.Run(c.args...)
+}
+
+// Len returns the number of funcs in the chain's shape.
+func (c *Chain) Len() int {
+	return c.Shape.Nodes * c.Shape.Funcs
+}
+
+// returns a func which spins for d
+func spin(d time.Duration) func() {
+	if d <= 0 {
+		return func() {}
+	}
+	return func() {
+		for start := time.Now(); time.Since(start) < d; {
+			// nop
+		}
+	}
+}
+
+// Benchmark runs a chain of the given shape b.N times, reporting
+// allocations along with ns/func, the time taken per func called, and
+// overhead-ns/func, the part of it not spent in the funcs themselves.
+func Benchmark(b *testing.B, s Shape) {
+	c := Build(s)
+	funcs := c.Len()
+	if funcs == 0 {
+		b.Skip("chain has no funcs")
+	}
+	direct := c.direct()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.Run(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	per := float64(b.Elapsed().Nanoseconds()) / float64(b.N*funcs)
+	b.ReportMetric(per, "ns/func")
+	b.ReportMetric(per-direct, "overhead-ns/func")
+}
+
+// estimates how long it takes to call one of the chain's funcs directly
+func (c *Chain) direct() float64 {
+	const n = 1000
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		c.work()
+	}
+	return float64(time.Since(start).Nanoseconds()) / n
+}
+
+// Sweep runs Benchmark for each shape as a sub-benchmark named after it.
+func Sweep(b *testing.B, shapes ...Shape) {
+	for _, s := range shapes {
+		s := s
+		b.Run(s.String(), func(b *testing.B) {
+			Benchmark(b, s)
+		})
+	}
+}
+
+// Load configures a load test, see LoadTest.
+type Load struct {
+	// Concurrency is the number of goroutines running the chain
+	// back to back, 1 if not positive.
+	Concurrency int
+	// Duration is how long the chain is run for.
+	Duration time.Duration
+}
+
+// LoadResult reports the outcome of a load test. Latencies are those of
+// individual runs.
+type LoadResult struct {
+	Runs    int
+	Errors  int
+	Elapsed time.Duration
+	P50     time.Duration
+	P99     time.Duration
+	Max     time.Duration
+}
+
+// RunsPerSecond returns the throughput of the load test.
+func (r *LoadResult) RunsPerSecond() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Runs) / r.Elapsed.Seconds()
+}
+
+// LoadTest runs the chain concurrently according to l and reports how it
+// coped.
+func (c *Chain) LoadTest(l Load) *LoadResult {
+	workers := l.Concurrency
+	if workers <= 0 {
+		workers = 1
+	}
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	res := &LoadResult{}
+	var latencies []time.Duration
+	start := time.Now()
+	deadline := start.Add(l.Duration)
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			var mine []time.Duration
+			errs := 0
+			for time.Now().Before(deadline) {
+				t := time.Now()
+				if c.Run() != nil {
+					errs++
+				}
+				mine = append(mine, time.Since(t))
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			latencies = append(latencies, mine...)
+			res.Errors += errs
+		}()
+	}
+	wg.Wait()
+	res.Elapsed = time.Since(start)
+	res.Runs = len(latencies)
+	if res.Runs > 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		res.P50 = latencies[res.Runs/2]
+		res.P99 = latencies[res.Runs*99/100]
+		res.Max = latencies[res.Runs-1]
+	}
+	return res
+}
@@ -0,0 +1,41 @@
+package bench_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jsipprell/go-chain/bench"
+)
+
+func TestBuild(t *testing.T) {
+	for _, sig := range []bench.Signature{bench.Plain, bench.Args, bench.Typed, bench.Context} {
+		c := bench.Build(bench.Shape{Nodes: 3, Funcs: 4, Signature: sig})
+		if n := len(c.Nodes()); n != 3 {
+			t.Errorf("%v: chain has %d nodes", sig, n)
+		}
+		if n := c.Root.Len(); n != c.Len() {
+			t.Errorf("%v: chain has %d funcs, want %d", sig, n, c.Len())
+		}
+		if err := c.Run(); err != nil {
+			t.Errorf("%v: %v", sig, err)
+		}
+	}
+}
+
+func TestLoadTest(t *testing.T) {
+	c := bench.Build(bench.Shape{Nodes: 2, Funcs: 2})
+	res := c.LoadTest(bench.Load{Concurrency: 4, Duration: 20 * time.Millisecond})
+	if res.Runs == 0 || res.Errors != 0 || res.Max < res.P50 || res.RunsPerSecond() <= 0 {
+		t.Errorf("load test reported %+v", res)
+	}
+}
+
+func BenchmarkShapes(b *testing.B) {
+	bench.Sweep(b,
+		bench.Shape{Nodes: 1, Funcs: 100},
+		bench.Shape{Nodes: 10, Funcs: 10},
+		bench.Shape{Nodes: 10, Funcs: 10, Signature: bench.Args},
+		bench.Shape{Nodes: 10, Funcs: 10, Signature: bench.Typed},
+		bench.Shape{Nodes: 10, Funcs: 10, Signature: bench.Context},
+	)
+}
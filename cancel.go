@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrAbandoned is the error of a run which finished without waiting for
+// funcs that were still running when its grace period ran out (see
+// WithGracePeriod).
+var ErrAbandoned = errors.New("run abandoned funcs still running after grace period")
+
+// WithGracePeriod bounds how long a run waits for funcs that are already
+// running once it has been cancelled, whether by Execution.Cancel(), a
+// StopChain decision (see Predicate.OnError) or its context being done.
+// Funcs can't be interrupted so they are expected to cooperate by
+// watching the context they are passed by RunContext(), which is
+// cancelled in all of these cases. Any still running after d are
+// abandoned: the run finishes with ErrAbandoned and anything they do
+// after that is not reflected in its errors or statistics. By default a
+// run waits for its funcs indefinitely.
+func WithGracePeriod(d time.Duration) Option {
+	return func(o *options) {
+		o.grace = d
+	}
+}
+
+// Cancel stops the run from starting any more funcs and cancels the
+// context being passed to funcs (if it is a context run). Funcs which are
+// already running are waited for, see WithGracePeriod. If any funcs were
+// kept from running the run's error is context.Canceled, whether or not it
+// is a context run.
+func (e *Execution) Cancel() {
+	atomic.StoreInt32(&e.cancelRequested, 1)
+	atomic.StoreInt32(&e.aborted, 1)
+	e.stop()
+}
+
+// signals that the run has been cut short
+func (e *Execution) stop() {
+	e.halt.Do(func() {
+		close(e.halted)
+		if e.cancelCtx != nil {
+			e.cancelCtx()
+		}
+	})
+}
+
+// waits for every worker to exit or, once the run is cancelled, at most
+// for the grace period
+func (e *Execution) awaitWorkers() {
+	grace := e.snap.opts.grace
+	if grace <= 0 && e.planDone == nil {
+		e.workers.Wait()
+		return
+	}
+	idle := make(chan struct{})
+	go func() {
+		e.workers.Wait()
+		close(idle)
+	}()
+	if e.planDone != nil {
+		e.awaitDrained(idle)
+		return
+	}
+	var ctxDone <-chan struct{}
+	if e.ctx != nil {
+		ctxDone = e.ctx.Done()
+	}
+	select {
+	case <-idle:
+		return
+	case <-e.halted:
+	case <-ctxDone:
+	}
+	t := time.NewTimer(grace)
+	defer t.Stop()
+	select {
+	case <-idle:
+	case <-t.C:
+		e.lock.Lock()
+		e.abandoned = true
+		e.err = ErrAbandoned
+		e.lock.Unlock()
+	}
+}
@@ -0,0 +1,74 @@
+package chain_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestCancel(t *testing.T) {
+	var later bool
+	c := chain.New()
+	p, _ := c.Register(func(ctx context.Context) {
+		// cooperates by watching its context
+		<-ctx.Done()
+	})
+	p.After(func() { later = true })
+	e := c.StartContext(context.Background())
+	e.Cancel()
+	e.Wait()
+	if later || e.State() != chain.Aborted {
+		t.Fatalf("cancelled run continued: %v, %v", later, e.State())
+	}
+}
+
+func TestGracePeriod(t *testing.T) {
+	started, stuck := make(chan struct{}), make(chan struct{})
+	defer close(stuck)
+	c := chain.New(chain.WithGracePeriod(10 * time.Millisecond))
+	c.Register(func(context.Context) {
+		close(started)
+		<-stuck
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	e := c.StartContext(ctx)
+	<-started
+	cancel()
+	select {
+	case <-e.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("run did not abandon uncooperative func")
+	}
+	if err := e.Err(); err != chain.ErrAbandoned {
+		t.Fatalf("expected ErrAbandoned, got %v", err)
+	}
+}
+
+func TestCancelWithoutContext(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	var later bool
+	c := chain.New()
+	p, _ := c.Register(func() {
+		close(started)
+		<-release
+	})
+	p.After(func() { later = true })
+	e := c.Start()
+	<-started
+	e.Cancel()
+	close(release)
+	if err := e.Err(); !errors.Is(err, context.Canceled) || later {
+		t.Fatalf("cancelled run returned %v (later node ran: %v)", err, later)
+	}
+
+	// cancelling a run that has finished changes nothing
+	e = chain.New().Start()
+	e.Wait()
+	e.Cancel()
+	if err := e.Err(); err != nil {
+		t.Fatalf("completed run returned %v after Cancel", err)
+	}
+}
@@ -26,18 +26,31 @@
 package chain // import "github.com/jsipprell/go-chain"
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"reflect"
+	"strings"
 	"sync"
 	"time"
+	"unsafe"
 )
 
 var (
 	ErrChainInvalidType = errors.New("attempt to register call chain using an invalid type")
 	ErrChainNoWaiter    = errors.New("chain node has no waiter")
 	ErrChainNotFunc     = errors.New("attempt to register a non-func")
+	ErrNameInUse        = errors.New("name is already in use by this chain")
+	ErrInvalidName      = errors.New("name is not a valid path")
+	ErrNilFunc          = errors.New("attempt to register a nil func")
+	ErrOrderConflict    = errors.New("Order() can only be used with Register()")
+	ErrInvalidHandle    = errors.New("handle does not refer to a registered func")
+	ErrSealed           = errors.New("chain has been sealed against modification")
+	ErrUnknownChain     = errors.New("no chain by that name in the group")
+	ErrCycle            = errors.New("ordering would create a cycle")
+	ErrNotChan          = errors.New("events must be a channel that can be received from")
 )
 
 type (
@@ -59,6 +72,22 @@ type (
 		Call(in []reflect.Value) (out []reflect.Value)
 	}
 
+	// Wrapping can be implemented by CallProxy values which wrap an
+	// application func (typically produced by a filter) so that the
+	// original func can be recovered for identity comparisons.
+	Wrapping interface {
+		Unwrap() interface{}
+	}
+
+	// Runner can be implemented by application types as a far simpler
+	// alternative to CallProxy. Runners are registered like any func and
+	// are passed the run's arguments as plain interface values. A Run
+	// method with an error result (such as Root's) is also accepted, so
+	// one chain can be registered as a step of another.
+	Runner interface {
+		Run(...interface{})
+	}
+
 	// Call is the most basic interface to a callchain node. It represents
 	// one or more executable function blocks.
 	// Register a new function call to be called back in this chain.
@@ -103,8 +132,14 @@ type (
 	//    // from this point all callchains have finished in the correct order
 	Call interface {
 		Register(...interface{}) (Predicate, error)
+		// Registers each func in the map under its name, all in this node.
+		// Names must be unique within the entire chain (see Lookup).
+		RegisterMap(map[string]interface{}) (Predicate, error)
 		Waiter() (Waiter, error)
 		Iterate(...*sync.WaitGroup) <-chan interface{}
+		// Returns a copy of the funcs registered with this node, without
+		// touching any waitgroups.
+		FuncsSlice() []interface{}
 	}
 
 	// Predicate represents a call chain relationship and has the following important
@@ -134,10 +169,56 @@ type (
 
 		After(...interface{}) (Predicate, error)
 		Before(...interface{}) (Predicate, error)
-		// NB: If First() is called more than once there can only be one true first.
+		// NB: If First() is called more than once there can only be one true first,
+		// see WithAnchors.
 		First(...interface{}) (Predicate, error)
-		// NB: If Last() is called more than once there can only be one true last.
+		// NB: If Last() is called more than once there can only be one true last,
+		// see WithAnchors.
 		Last(...interface{}) (Predicate, error)
+
+		// RegisterSeq() registers each func passed in its own new node
+		// following the receiver so that they run strictly in the order
+		// given. The last node is returned. All funcs are validated before
+		// any nodes are created, so either all or none are registered.
+		RegisterSeq(...interface{}) (Predicate, error)
+
+		// SpliceBefore() and SpliceAfter() insert copies of every node of
+		// another chain immediately before or after the receiver,
+		// preserving the other chain's internal order. Funcs are converted
+		// to the receiver's type (if it has one) and nothing is spliced if
+		// any of them are incompatible. The other chain is not modified.
+		SpliceBefore(Root) error
+		SpliceAfter(Root) error
+
+		// Position() returns the zero-based index of the node counting
+		// from the head of the chain.
+		Position() int
+
+		// Name() and SetName() get and set the node's name. Node names
+		// share a namespace with func names registered via RegisterMap()
+		// and must be unique within the chain. Names may be slash
+		// separated paths such as "db/migrate" (see NodesMatching).
+		Name() string
+		SetName(string) error
+
+		// WaitFor() makes the node wait, whenever its chain is run, until
+		// the node with the given name in another chain has completed in
+		// one of that chain's runs, that is every one of its funcs was
+		// called and none failed or was skipped. A node without any
+		// funcs completes once a run gets past the nodes before it, and
+		// a node checkpointed by an earlier run counts as completed when
+		// resumed. Once it has, the dependency is satisfied for good;
+		// this models lifecycle dependencies between independently
+		// started chains. Nodes with nothing to run don't wait.
+		WaitFor(Root, string) error
+
+		// OnError() sets a func to be called whenever a func in the node
+		// fails (by returning an error or panicking) during a run. What
+		// it returns decides how the run proceeds.
+		OnError(func(FuncInfo, error) Decision) error
+
+		// SetRecovery() replaces any error handler with a fixed policy.
+		SetRecovery(RecoveryPolicy) error
 	}
 
 	// Represents the root of an entire callchain, although this is somewhat arbitrary.
@@ -156,19 +237,169 @@ type (
 		// Returns the *current* total number of registered calls
 		Len() int
 
+		// Writes a human readable description of every node and the funcs
+		// registered with it (including where they were registered from).
+		Dump(io.Writer) error
+
+		// Visits every node in execution order along with a description of
+		// each func registered with it, stopping at the first error returned
+		// by the visitor. The chain is not locked while the visitor runs.
+		Walk(func(Predicate, []FuncInfo) error) error
+
+		// Returns a description of every registered func in execution
+		// order.
+		Funcs() []FuncInfo
+
+		// Returns the node with the given name or the node containing the
+		// func registered under that name.
+		Lookup(string) (Predicate, bool)
+
+		// Returns the node containing fn, which must be the very same func
+		// value passed to Register() (or one of its friends). CallProxy
+		// values are compared directly and are unwrapped if they implement
+		// Wrapping.
+		FindFunc(interface{}) (Predicate, bool)
+
+		// Registers every exported method of a receiver that is compatible
+		// with the chain's func type (any method at all for untyped
+		// chains) and, if any prefixes are given, whose name begins with
+		// one of them. Returns the number of methods registered.
+		RegisterMethods(interface{}, ...string) (int, error)
+
 		Validator() Validating
 		SetValidator(Validating) error
 
 		// Iterate over all the call chain nodes in execution order
 		IterateAll() <-chan Call
 
+		// Returns all the call chain nodes in execution order.
+		Nodes() []Call
+
+		// Returns the nodes, in execution order, whose names match a glob
+		// pattern as understood by path.Match, so "db/*" matches
+		// "db/migrate" and "db/connect" but not "db" or "db/migrate/up".
+		NodesMatching(string) ([]Predicate, error)
+
 		// Run the entire call chain, passing addl args to each function in turn.
-		Run(...interface{})
+		// Returns an error if the chain couldn't be run or if any function
+		// failed (see Execution.Err()).
+		Run(...interface{}) error
 
 		// Run the entire call chain through a filter, all functions which the
 		// filter returns true for will be executed with the arguments passed
 		// to RunFiltered
-		RunFiltered(func(interface{}, []interface{}) bool, ...interface{})
+		RunFiltered(func(interface{}, []interface{}) bool, ...interface{}) error
+
+		// Run only the suffix of the chain beginning with, or the prefix
+		// ending with, the given node (inclusive). Both panic if the node
+		// is not part of the chain.
+		RunFrom(Predicate, ...interface{}) error
+		RunUntil(Predicate, ...interface{}) error
+
+		// Run the entire call chain, folding the results of every func
+		// into a single value with reduce (see Snapshot.RunReduce()).
+		RunReduce(func(acc, result interface{}) interface{}, ...interface{}) (interface{}, error)
+
+		// Run the entire call chain under a context. Funcs whose first
+		// parameter is a context.Context are passed ctx automatically (so it
+		// must not be included in the args) and nodes not yet started when
+		// ctx is done are skipped.
+		RunContext(context.Context, ...interface{}) error
+
+		// Run the entire call chain, skipping any named node that the
+		// chain's CheckpointStore reports as already completed (see
+		// WithCheckpoints).
+		Resume(...interface{}) error
+
+		// Start is the asynchronous form of Run. It returns immediately
+		// with an Execution handle that can be used to wait for the run to
+		// finish and to retrieve its statistics.
+		Start(...interface{}) *Execution
+
+		// StartFiltered is the asynchronous form of RunFiltered.
+		StartFiltered(func(interface{}, []interface{}) bool, ...interface{}) *Execution
+
+		// Run the entire call chain, passing each func its own set of
+		// arguments (see PerNode, PerFunc and PerName). The run fails
+		// before anything is called if a func has no set.
+		RunFanOut(ArgSets) error
+
+		// StartFanOut is the asynchronous form of RunFanOut.
+		StartFanOut(ArgSets) *Execution
+
+		// Run only the nodes whose names match a glob pattern (see
+		// NodesMatching), in order. The nodes need not be adjacent.
+		RunMatching(string, ...interface{}) error
+
+		// Run the entire call chain, asking a selector about each func
+		// (in execution order, before anything runs) whether it should
+		// be skipped: Skip skips the func, StopNode it and the rest of
+		// its node and StopChain it and everything after it. Unlike
+		// funcs filtered out by RunFiltered, skipped funcs are reported
+		// as such in the run's statistics, trace and results, and the
+		// run takes care of any synchronization on their behalf.
+		RunSelect(func(FuncInfo, []interface{}) Decision, ...interface{}) error
+
+		// StartSelect is the asynchronous form of RunSelect.
+		StartSelect(func(FuncInfo, []interface{}) Decision, ...interface{}) *Execution
+
+		// StartContext is the asynchronous form of RunContext.
+		StartContext(context.Context, ...interface{}) *Execution
+
+		// StartResume is the asynchronous form of Resume.
+		StartResume(...interface{}) *Execution
+
+		// StartStepped starts a run in debugging mode where nodes are
+		// only released one at a time by calling Step() on the returned
+		// Execution.
+		StartStepped(...interface{}) *Execution
+
+		// Sets a func to be applied to the arguments of every run before
+		// anything is dispatched, which can normalize or augment them
+		// (inject defaults, wrap a logger, etc). If it returns an error
+		// the run fails with it and nothing runs. Nil removes any adapter.
+		SetArgAdapter(func([]interface{}) ([]interface{}, error)) error
+
+		// Sets a func to be called with the results of every func once a
+		// run has finished, in execution order, to check invariants that
+		// span the whole chain. An error it returns is added to the run's
+		// errors. It isn't called if the run couldn't be started. Nil
+		// removes any post validator.
+		SetPostValidator(func([]Result) error) error
+
+		// Returns a frozen copy of the current node and func layout which
+		// can be run independently of any further registrations. The copy
+		// is shared by every call until the chain is next modified; the
+		// first call after that takes the chain lock to make a new one.
+		Snapshot() *Snapshot
+
+		// Register funcs to be called at the start and end of every run,
+		// in the order registered. Start funcs are called before anything
+		// in the run (and may use RunInfo.Set() to pass values to funcs
+		// and done funcs), done funcs after everything has finished and
+		// are passed the run's error (see Execution.Err()).
+		OnRunStart(func(*RunInfo))
+		OnRunDone(func(*RunInfo, error))
+
+		// Registers a func to be called whenever the chain is modified.
+		// Listeners are called synchronously after the chain has been
+		// unlocked, so they are free to inspect or even modify the chain.
+		OnChange(func(Event))
+
+		// Seals the chain so that any further attempt to modify it fails
+		// with ErrSealed. Clones of a sealed chain are not sealed.
+		Seal()
+		Sealed() bool
+
+		// Stages a group of registrations through a Builder and applies them
+		// all at once, or none at all if any of them fails or the func
+		// returns an error or panics. The chain is locked while the func
+		// runs.
+		Batch(func(Builder) error) error
+
+		// Returns the events recorded by the most recent runs, oldest
+		// first, if tracing was enabled with WithTrace().
+		Trace() []TraceEvent
 
 		// Clones an entire nodechain. Cloned chains run independent from their
 		// origin source but maintain the same internal relationships
@@ -224,17 +455,35 @@ func assertCall(chain Call, fp interface{}, e error) (i interface{}, err error)
 	var ok bool
 
 	err = e
+	if fp == nil && err == nil {
+		if cn, ok := chain.(*chainNode); ok && cn.opts.strict {
+			err = ErrNilFunc
+		}
+		return
+	}
 	if fp != nil && err == nil {
-		if val, ok = fp.(reflect.Value); !ok {
+		if val, ok = fp.(reflect.Value); ok {
+			T = val.Type()
+		} else {
 			val = reflect.ValueOf(fp)
 			T = reflect.TypeOf(fp)
 			// NB: CallProxy interfaces are allowed even if they are aren't funcs,
 			// this allows apps to fake the reflection interface on their own
 			// receivers.
+			if b, ok := fp.(*bindError); ok {
+				err = b.err
+				return
+			}
 			if _, ok := fp.(CallProxy); ok && T.Kind() != reflect.Func {
 				i = fp
 				return
 			}
+			if T.Kind() != reflect.Func {
+				if cp := asRunner(chain, val); cp != nil {
+					i = cp
+					return
+				}
+			}
 		}
 	}
 	if val.IsValid() {
@@ -242,8 +491,25 @@ func assertCall(chain Call, fp interface{}, e error) (i interface{}, err error)
 			err = ErrChainNotFunc
 			return
 		}
+		if cn, ok := chain.(*chainNode); ok && cn.opts.strict {
+			ftype := cn.ftype
+			if cn.opts.prefixFuncs && ftype != nil && isPrefix(T, ftype) {
+				// prefixes already match the chain's types exactly
+				ftype = nil
+			}
+			if err = strictCheck(val, ftype); err != nil {
+				return
+			}
+		}
+		if cn, ok := chain.(*chainNode); ok && cn.opts.prefixFuncs && cn.ftype != nil && isPrefix(T, cn.ftype) {
+			i = prefixProxy{val}
+			return
+		}
 		if cn, ok := chain.(*chainNode); ok && cn.ftype != nil {
-			if T.ConvertibleTo(cn.ftype) {
+			if T == cn.ftype {
+				i = fp
+				return
+			} else if T.ConvertibleTo(cn.ftype) {
 				i = val.Convert(cn.ftype).Interface()
 				return
 			} else {
@@ -302,23 +568,82 @@ func validate(chain Call, fn ...interface{}) (interface{}, error) {
 	return nil, err
 }
 
+// a single registered func and the site it was registered from. id is
+// the func as handed to filters and direct is set for funcs taking no
+// arguments and returning nothing so they can be called without
+// reflection. Both are computed once at registration time.
+type entry struct {
+	fn     CallProxy
+	id     interface{}
+	direct func()
+	run    func([]interface{}) error
+	site   string
+	name   string
+	ctx    bool
+
+	// set by RegisterOptions
+	key      string
+	done     func(string) (bool, error)
+	expiry   *expiry
+	recovery *RecoveryPolicy
+	skipIf   func([]interface{}) bool
+}
+
+var plainFuncType = reflect.TypeOf(func() {})
+
+func newEntry(cp CallProxy, site string) *entry {
+	return initEntry(&entry{}, cp, site)
+}
+
+// fills in an entry for a func, e usually comes from the chain's arena
+func initEntry(e *entry, cp CallProxy, site string) *entry {
+	*e = entry{fn: cp, id: cp, site: site}
+	if val, ok := cp.(reflect.Value); ok {
+		e.id = val.Interface()
+		e.ctx = takesContext(val.Type())
+		if T := val.Type(); T.ConvertibleTo(plainFuncType) && !val.IsNil() {
+			e.direct = val.Convert(plainFuncType).Interface().(func())
+		}
+	} else if p, ok := cp.(runnerProxy); ok {
+		e.run = p.run
+	} else if p, ok := cp.(prefixProxy); ok {
+		e.id = p.Unwrap()
+		e.ctx = takesContext(p.fn.Type())
+	}
+	return e
+}
+
 type chainNode struct {
 	lock   sync.Locker
-	funcs  []CallProxy
-	wait   *sync.WaitGroup
+	funcs  []*entry
+	wait   Waiter
 	before *chainNode
 	after  *chainNode
+	name   string
+
+	// set for nodes created by Order()
+	weighted bool
+	weight   int
+
+	// set for nodes claimed by First() and Last(), see WithAnchors
+	anchor anchorKind
+
+	deps    []dependency
+	onError func(FuncInfo, error) Decision
 
 	ftype     reflect.Type
 	validator Validating
+	opts      *options
 }
 
 // Returns a new root callchain that has no validator
-func New() Root {
+func New(opts ...Option) Root {
+	o := newOptions(opts)
 	return &chainNode{
 		lock:  &sync.Mutex{},
-		funcs: make([]CallProxy, 0, 1),
-		wait:  &sync.WaitGroup{},
+		funcs: make([]*entry, 0, 1),
+		wait:  o.newWaiter(),
+		opts:  o,
 	}
 }
 
@@ -335,44 +660,54 @@ func New() Root {
 // NB: MyChain.Register() and friends at this point will
 // attempt to convert any arguments to MyFuncs and if
 // they are unable to do this will return an error.
-func NewTyped(t interface{}) Root {
+//
+// If the func type's final result is an error, any non-nil
+// errors returned are collected by each run and are available
+// from Execution.Errors(). See also WithStopOnError.
+func NewTyped(t interface{}, opts ...Option) Root {
 	var T reflect.Type = reflect.TypeOf(t)
 
 	if T.Kind() != reflect.Func {
 		log.Panicf("type <%v> is not a func", T)
 	}
+	o := newOptions(opts)
 	return &chainNode{
 		lock:  &sync.Mutex{},
-		funcs: make([]CallProxy, 0, 1),
-		wait:  &sync.WaitGroup{},
+		funcs: make([]*entry, 0, 1),
+		wait:  o.newWaiter(),
 		ftype: T,
+		opts:  o,
 	}
 }
 
 // Returns a new root callchain that has a 	user supplied validator
 // and (optionally) filter.
-func NewValidating(validator Validating) Root {
+func NewValidating(validator Validating, opts ...Option) Root {
+	o := newOptions(opts)
 	return &chainNode{
 		lock:      &sync.Mutex{},
-		funcs:     make([]CallProxy, 0, 1),
-		wait:      &sync.WaitGroup{},
+		funcs:     make([]*entry, 0, 1),
+		wait:      o.newWaiter(),
 		validator: validator,
+		opts:      o,
 	}
 }
 
 // A combination of NewTyped and NewValidating.
-func NewTypedValidating(t interface{}, validator Validating) Root {
+func NewTypedValidating(t interface{}, validator Validating, opts ...Option) Root {
 	var T reflect.Type = reflect.TypeOf(t)
 
 	if T.Kind() != reflect.Func {
 		log.Panicf("type <%v> is not a func", T)
 	}
+	o := newOptions(opts)
 	return &chainNode{
 		lock:      &sync.Mutex{},
-		funcs:     make([]CallProxy, 0, 1),
-		wait:      &sync.WaitGroup{},
+		funcs:     make([]*entry, 0, 1),
+		wait:      o.newWaiter(),
 		validator: validator,
 		ftype:     T,
+		opts:      o,
 	}
 }
 
@@ -381,6 +716,11 @@ func (cn *chainNode) Validator() Validating {
 }
 
 func (cn *chainNode) SetValidator(v Validating) error {
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	if err := cn.checkSealed(); err != nil {
+		return err
+	}
 	for n := cn.getFirst(); n != nil; n = n.getNext() {
 		n.validator = v
 	}
@@ -389,13 +729,23 @@ func (cn *chainNode) SetValidator(v Validating) error {
 
 func (cn *chainNode) Clone() Root {
 	var root Root
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
 	n := cn.getFirst()
 	if n == nil {
 		panic("cannot clone nil chain")
 	}
 	rn := clone(n, nil)
+	rn.opts.listeners = nil
+	rn.opts.pending = nil
+	rn.opts.trace = rn.opts.trace.fresh()
+	rn.opts.sealed = false
+	rn.opts.cache = &snapCache{}
+	rn.opts.latches = newLatchSet()
+	rn.opts.debounce = rn.opts.debounce.fresh()
+	rn.opts.flight = rn.opts.flight.fresh()
 	root = rn
-	for n = rn.after; n != nil; n = n.after {
+	for n = n.after; n != nil; n = n.after {
 		rn.after = clone(n, root)
 		rn.after.before = rn
 		rn = rn.after
@@ -405,37 +755,53 @@ func (cn *chainNode) Clone() Root {
 
 func clone(src *chainNode, root Root) (n *chainNode) {
 	var L sync.Locker
+	var O *options
 
 	if rn, ok := root.(*chainNode); ok {
 		L = rn.lock
+		O = rn.opts
 	} else {
 		L = &sync.Mutex{}
+		O = src.opts.clone()
+		O.arena = &arena{}
 	}
 
-	n = &chainNode{
-		funcs:     make([]CallProxy, len(src.funcs), cap(src.funcs)),
-		wait:      &sync.WaitGroup{},
+	n = O.arena.node()
+	*n = chainNode{
+		funcs:     make([]*entry, len(src.funcs), cap(src.funcs)),
+		wait:      O.newWaiter(),
 		lock:      L,
 		validator: src.validator,
 		ftype:     src.ftype,
+		opts:      O,
+		name:      src.name,
+		weighted:  src.weighted,
+		weight:    src.weight,
+		anchor:    src.anchor,
+		deps:      append([]dependency(nil), src.deps...),
+		onError:   src.onError,
 	}
 
-	copy(n.funcs, src.funcs)
+	for i, e := range src.funcs {
+		c := O.arena.entry()
+		*c = *e
+		c.expiry = e.expiry.clone()
+		n.funcs[i] = c
+	}
 	return
 }
 
 func dup(old *chainNode) (n *chainNode) {
-	n = &chainNode{
-		funcs: make([]CallProxy, 0, 1),
-		wait:  &sync.WaitGroup{},
-	}
 	if old != nil {
+		n = old.opts.arena.node()
 		n.lock = old.lock
 		n.validator = old.validator
 		n.ftype = old.ftype
+		n.opts = old.opts
 	} else {
-		n.lock = &sync.Mutex{}
+		n = &chainNode{lock: &sync.Mutex{}, opts: newOptions(nil)}
 	}
+	n.wait = n.opts.newWaiter()
 	return
 }
 
@@ -461,6 +827,7 @@ func (cn *chainNode) insertBefore() (n *chainNode) {
 	}
 	cn.before = n
 	n.after = cn
+	n.queue(Event{Kind: EventNodeInserted, Node: n})
 	return
 }
 
@@ -472,6 +839,7 @@ func (cn *chainNode) insertAfter() (n *chainNode) {
 	}
 	cn.after = n
 	n.before = cn
+	n.queue(Event{Kind: EventNodeInserted, Node: n})
 	return
 }
 
@@ -521,6 +889,43 @@ func (cn *chainNode) Middle() Predicate {
 	return n
 }
 
+func (cn *chainNode) Position() (i int) {
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+
+	for n := cn.before; n != nil; n = n.before {
+		i++
+	}
+	return
+}
+
+// Distance returns the number of nodes separating a from b: positive if b
+// runs after a, negative if it runs before and zero if they are the same
+// node. Distance panics if a and b do not belong to the same chain.
+func Distance(a, b Predicate) int {
+	an, aok := a.(*chainNode)
+	bn, bok := b.(*chainNode)
+	if !aok || !bok {
+		panic("chain.Distance requires two chain nodes")
+	}
+	an.lock.Lock()
+	defer an.lock.Unlock()
+
+	i := 0
+	for n := an; n != nil; n, i = n.after, i+1 {
+		if n == bn {
+			return i
+		}
+	}
+	i = 0
+	for n := an; n != nil; n, i = n.before, i-1 {
+		if n == bn {
+			return i
+		}
+	}
+	panic("chain.Distance called with nodes from different chains")
+}
+
 func (cn *chainNode) Len() int {
 	cn.lock.Lock()
 	defer cn.lock.Unlock()
@@ -528,6 +933,55 @@ func (cn *chainNode) Len() int {
 	return chainLen(cn.getFirst())
 }
 
+func (cn *chainNode) FindFunc(fn interface{}) (Predicate, bool) {
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+
+	for n := cn.getFirst(); n != nil; n = n.getNext() {
+		for _, e := range n.funcs {
+			if sameFunc(e.fn, fn) {
+				return n, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// sameFunc reports whether a registered CallProxy is fn or wraps it.
+func sameFunc(cp CallProxy, fn interface{}) bool {
+	if val, ok := cp.(reflect.Value); ok {
+		if fv, ok := fn.(reflect.Value); ok {
+			fn = fv.Interface()
+		}
+		if T := reflect.TypeOf(fn); T == nil || T.Kind() != reflect.Func {
+			return false
+		}
+		return funcIdentity(val.Interface()) == funcIdentity(fn)
+	}
+	if reflect.TypeOf(cp).Comparable() && reflect.TypeOf(fn) == reflect.TypeOf(cp) && fn == interface{}(cp) {
+		return true
+	}
+	if w, ok := cp.(Wrapping); ok {
+		if inner := w.Unwrap(); inner != nil {
+			if icp, ok := inner.(CallProxy); ok {
+				return sameFunc(icp, fn)
+			}
+			if T := reflect.TypeOf(inner); T.Kind() != reflect.Func {
+				return T.Comparable() && reflect.TypeOf(fn) == T && inner == fn
+			}
+			return sameFunc(reflect.ValueOf(inner), fn)
+		}
+	}
+	return false
+}
+
+// funcIdentity returns the closure pointer of a func stored in an
+// interface. Unlike reflect.Value.Pointer() this distinguishes between
+// closures sharing the same code and survives type conversion.
+func funcIdentity(fn interface{}) uintptr {
+	return (*[2]uintptr)(unsafe.Pointer(&fn))[1]
+}
+
 // just like reflect.ValueOf but give us a pass on CallProxy
 // fakes by not reflecting them.
 func valueOf(i interface{}) CallProxy {
@@ -539,59 +993,350 @@ func valueOf(i interface{}) CallProxy {
 }
 
 func (cn *chainNode) Before(fn ...interface{}) (Predicate, error) {
+	site := callSite()
+	defer cn.notify()
 	cn.lock.Lock()
 	defer cn.lock.Unlock()
+	if err := cn.checkSealed(); err != nil {
+		return cn, siteError(site, err)
+	}
+	if err := cn.checkAnchor(true); err != nil {
+		return cn, siteError(site, err)
+	}
 	n := cn.insertBefore()
-
-	f, err := validate(n, fn...)
-	if err == nil && f != nil {
-		n.funcs = append(n.funcs, valueOf(f))
+	funcs, reg, err := validateAll(n, fn)
+	if err == nil && reg.ordered() {
+		err = ErrOrderConflict
+	}
+	if err == nil {
+		n.addAll(funcs, site, reg)
 	}
-	return n, err
+	return n, siteError(site, err)
 }
 
 func (cn *chainNode) After(fn ...interface{}) (Predicate, error) {
+	site := callSite()
+	defer cn.notify()
 	cn.lock.Lock()
 	defer cn.lock.Unlock()
+	if err := cn.checkSealed(); err != nil {
+		return cn, siteError(site, err)
+	}
+	if err := cn.checkAnchor(false); err != nil {
+		return cn, siteError(site, err)
+	}
 	n := cn.insertAfter()
-	f, err := validate(n, fn...)
-	if err == nil && f != nil {
-		n.funcs = append(n.funcs, valueOf(f))
+	funcs, reg, err := validateAll(n, fn)
+	if err == nil && reg.ordered() {
+		err = ErrOrderConflict
 	}
-	return n, err
+	if err == nil {
+		n.addAll(funcs, site, reg)
+	}
+	return n, siteError(site, err)
 }
 
 func (cn *chainNode) First(fn ...interface{}) (Predicate, error) {
+	site := callSite()
+	defer cn.notify()
 	cn.lock.Lock()
 	defer cn.lock.Unlock()
-	n := cn.getFirst().insertBefore()
-	f, err := validate(n, fn...)
-	if err == nil && f != nil {
-		n.funcs = append(n.funcs, valueOf(f))
+	if err := cn.checkSealed(); err != nil {
+		return cn, siteError(site, err)
+	}
+	n, err := cn.firstNode()
+	if err != nil {
+		return cn, siteError(site, err)
 	}
-	return n, err
+	funcs, reg, err := validateAll(n, fn)
+	if err == nil && reg.ordered() {
+		err = ErrOrderConflict
+	}
+	if err == nil {
+		n.addAll(funcs, site, reg)
+	}
+	return n, siteError(site, err)
 }
 
 func (cn *chainNode) Last(fn ...interface{}) (Predicate, error) {
+	site := callSite()
+	defer cn.notify()
 	cn.lock.Lock()
 	defer cn.lock.Unlock()
-	n := cn.getLast().insertAfter()
-	f, err := validate(n, fn...)
-	if err == nil && f != nil {
-		n.funcs = append(n.funcs, valueOf(f))
+	if err := cn.checkSealed(); err != nil {
+		return cn, siteError(site, err)
+	}
+	n, err := cn.lastNode()
+	if err != nil {
+		return cn, siteError(site, err)
+	}
+	funcs, reg, err := validateAll(n, fn)
+	if err == nil && reg.ordered() {
+		err = ErrOrderConflict
 	}
-	return n, err
+	if err == nil {
+		n.addAll(funcs, site, reg)
+	}
+	return n, siteError(site, err)
+}
+
+func (cn *chainNode) RegisterSeq(fns ...interface{}) (Predicate, error) {
+	site := callSite()
+	defer cn.notify()
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	if err := cn.checkSealed(); err != nil {
+		return cn, siteError(site, err)
+	}
+	if err := cn.checkAnchor(false); err != nil {
+		return cn, siteError(site, err)
+	}
+
+	fns, reg := splitOptions(fns)
+	if reg.ordered() {
+		return cn, siteError(site, ErrOrderConflict)
+	}
+	fns = reg.adaptAll(cn.ftype, fns)
+	valid := make([]interface{}, len(fns))
+	for i, fn := range fns {
+		f, err := validate(cn, fn)
+		if err != nil {
+			return cn, siteError(site, &ArgumentError{Index: i, Err: err})
+		}
+		valid[i] = f
+	}
+	n := cn
+	for _, f := range valid {
+		n = n.insertAfter()
+		n.addAll([]interface{}{f}, site, reg)
+	}
+	return n, nil
+}
+
+func (cn *chainNode) SpliceBefore(other Root) error {
+	return cn.splice(other, true)
+}
+
+func (cn *chainNode) SpliceAfter(other Root) error {
+	return cn.splice(other, false)
+}
+
+func (cn *chainNode) splice(other Root, before bool) error {
+	src, ok := asNode(other)
+	if !ok {
+		return ErrChainInvalidType
+	}
+	// copy the other chain's layout first, it may well share our lock
+	type segNode struct {
+		funcs    []entry
+		name     string
+		weighted bool
+		weight   int
+		anchor   anchorKind
+		deps     []dependency
+		onError  func(FuncInfo, error) Decision
+	}
+	var segment []segNode
+	src.lock.Lock()
+	for n := src.getFirst(); n != nil; n = n.getNext() {
+		s := segNode{
+			funcs:    make([]entry, len(n.funcs)),
+			name:     n.name,
+			weighted: n.weighted,
+			weight:   n.weight,
+			anchor:   n.anchor,
+			deps:     append([]dependency(nil), n.deps...),
+			onError:  n.onError,
+		}
+		for i, e := range n.funcs {
+			s.funcs[i] = *e
+			s.funcs[i].expiry = e.expiry.clone()
+		}
+		segment = append(segment, s)
+	}
+	src.lock.Unlock()
+
+	defer cn.notify()
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	if err := cn.checkSealed(); err != nil {
+		return err
+	}
+	if err := cn.checkAnchor(before); err != nil {
+		return err
+	}
+
+	if cn.ftype != nil {
+		for _, s := range segment {
+			for i := range s.funcs {
+				e := &s.funcs[i]
+				val, ok := e.fn.(reflect.Value)
+				if !ok || val.Type() == cn.ftype {
+					continue
+				}
+				if !val.Type().ConvertibleTo(cn.ftype) {
+					return siteError(e.site, fmt.Errorf("%v is not compatible with %v", val.Type(), cn.ftype))
+				}
+				conv := newEntry(val.Convert(cn.ftype), e.site)
+				e.fn, e.id, e.direct, e.ctx = conv.fn, conv.id, conv.direct, conv.ctx
+			}
+		}
+	}
+
+	for _, s := range segment {
+		if err := cn.checkName(s.name); err != nil {
+			return err
+		}
+		for _, e := range s.funcs {
+			if err := cn.checkName(e.name); err != nil {
+				return err
+			}
+		}
+	}
+
+	n := cn
+	for _, s := range segment {
+		if before {
+			n = cn.insertBefore()
+		} else {
+			n = n.insertAfter()
+		}
+		n.name, n.weighted, n.weight = s.name, s.weighted, s.weight
+		n.deps, n.onError = s.deps, s.onError
+		// anchors only survive at the end of the chain they guard
+		if (s.anchor == anchorFirst && n.before == nil) || (s.anchor == anchorLast && n.after == nil) {
+			n.anchor = s.anchor
+		}
+		for i := range s.funcs {
+			e := cn.opts.arena.entry()
+			*e = s.funcs[i]
+			n.funcs = append(n.funcs, e)
+			n.queue(Event{Kind: EventFuncRegistered, Node: n, Func: funcType(e.fn)})
+		}
+	}
+	return nil
 }
 
 func (cn *chainNode) Register(fn ...interface{}) (Predicate, error) {
 	//log.Printf("REGISTER %v",fn)
-	f, err := validate(cn, fn...)
-	if err == nil && f != nil {
-		cn.lock.Lock()
-		defer cn.lock.Unlock()
-		cn.funcs = append(cn.funcs, valueOf(f))
+	site := callSite()
+	defer cn.notify()
+	funcs, reg, err := validateAll(cn, fn)
+	if err != nil {
+		return cn, siteError(site, err)
+	}
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	if err := cn.checkSealed(); err != nil {
+		return cn, siteError(site, err)
 	}
-	return cn, err
+	n := cn
+	if reg.ordered() {
+		n = cn.ordered(*reg.order)
+	}
+	n.addAll(funcs, site, reg)
+	return n, nil
+}
+
+// ArgumentError identifies which of several funcs passed to Register() or
+// one of its friends could not be registered. Index is zero-based.
+type ArgumentError struct {
+	Index int
+	Err   error
+}
+
+func (e *ArgumentError) Error() string {
+	return fmt.Sprintf("argument #%d: %v", e.Index+1, e.Err)
+}
+
+func (e *ArgumentError) Unwrap() error {
+	return e.Err
+}
+
+// validateAll validates the arguments passed to Register() and friends.
+// Any RegisterOptions are removed first. If the node has a validator all
+// remaining arguments are handed to it together and describe a single func,
+// otherwise each argument is a separate func which is validated on its own.
+func validateAll(cn *chainNode, fn []interface{}) ([]interface{}, *registration, error) {
+	fn, reg := splitOptions(fn)
+	fn = reg.adaptAll(cn.ftype, fn)
+	if cn.validator != nil || len(fn) < 2 {
+		f, err := validate(cn, fn...)
+		return []interface{}{f}, reg, err
+	}
+	funcs := make([]interface{}, len(fn))
+	for i := range fn {
+		f, err := validate(cn, fn[i])
+		if err != nil {
+			return nil, nil, &ArgumentError{Index: i, Err: err}
+		}
+		funcs[i] = f
+	}
+	return funcs, reg, nil
+}
+
+// appends validated funcs to the node, must be called with the chain
+// locked.
+func (cn *chainNode) addAll(funcs []interface{}, site string, reg *registration) {
+	for _, f := range funcs {
+		if f != nil {
+			reg.apply(cn, cn.add(f, site))
+		}
+	}
+}
+
+func (cn *chainNode) RegisterMethods(receiver interface{}, prefixes ...string) (count int, err error) {
+	val := reflect.ValueOf(receiver)
+	if !val.IsValid() {
+		return 0, siteError(callSite(), ErrChainInvalidType)
+	}
+	T := val.Type()
+	for i := 0; i < T.NumMethod(); i++ {
+		name := T.Method(i).Name
+		if len(prefixes) > 0 {
+			matched := false
+			for _, p := range prefixes {
+				if strings.HasPrefix(name, p) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				continue
+			}
+		}
+		m := val.Method(i)
+		if cn.ftype != nil && !m.Type().ConvertibleTo(cn.ftype) {
+			continue
+		}
+		if _, err = cn.Register(m.Interface()); err != nil {
+			return
+		}
+		count++
+	}
+	return
+}
+
+// appends a validated func to the node, must be called with the chain
+// locked.
+func (cn *chainNode) add(f interface{}, site string) *entry {
+	cp := valueOf(f)
+	e := initEntry(cn.opts.arena.entry(), cp, site)
+	cn.funcs = append(cn.funcs, e)
+	cn.queue(Event{Kind: EventFuncRegistered, Node: cn, Func: funcType(cp)})
+	return e
+}
+
+// returns the type of the func behind a CallProxy, or the type of the proxy
+// itself if it isn't a reflected func.
+func funcType(cp CallProxy) reflect.Type {
+	if p, ok := cp.(prefixProxy); ok {
+		return p.fn.Type()
+	}
+	if val, ok := cp.(reflect.Value); ok {
+		return val.Type()
+	}
+	return reflect.TypeOf(cp)
 }
 
 func (cn *chainNode) Waiter() (Waiter, error) {
@@ -615,15 +1360,21 @@ func (nullWaiterFunc) Wait() {}
 // will always do nothing when Wait() is called on it.
 var NullWaiter = nullWaiterFunc(func() {})
 
-func addAll(n int, W ...*sync.WaitGroup) {
-	for _, w := range W {
-		w.Add(n)
-	}
+// delayWaiter waits on another Waiter and then pauses for a fixed
+// duration before returning.
+type delayWaiter struct {
+	W Waiter
+	d time.Duration
 }
 
-func doneAll(W ...*sync.WaitGroup) {
+func (dw *delayWaiter) Wait() {
+	dw.W.Wait()
+	time.Sleep(dw.d)
+}
+
+func addAll(n int, W ...*sync.WaitGroup) {
 	for _, w := range W {
-		w.Done()
+		w.Add(n)
 	}
 }
 
@@ -641,121 +1392,94 @@ func WaitGroup(chain Call) (wg *sync.WaitGroup) {
 }
 
 func (cn *chainNode) RunFiltered(filter func(interface{}, []interface{}) bool,
-	args ...interface{}) {
-	cn.lock.Lock()
-	defer cn.lock.Unlock()
-	vals := make([]reflect.Value, len(args))
-	for i, v := range args {
-		vals[i] = reflect.ValueOf(v)
-	}
-	gSync := &sync.WaitGroup{}
-	defer gSync.Wait()
-	var chainWait Waiter = NullWaiter
-
-	for n := range cn.IterateAll() {
-		wg := WaitGroup(n)
-		for fn := range iterate(n.(*chainNode), gSync) {
-			var i interface{}
-			if val, ok := fn.(reflect.Value); ok {
-				i = val.Interface()
-			} else {
-				i = fn
-			}
-			if !filter(i, args) {
-				gSync.Done()
-				wg.Done()
-				continue
-			}
-			go func(f CallProxy, oWait Waiter, iWait *sync.WaitGroup, in []reflect.Value) {
-				defer gSync.Done()
-				if iWait != nil {
-					defer iWait.Done()
-				}
-				oWait.Wait()
-				_ = f.Call(in)
-			}(fn, chainWait, wg, vals)
-		}
-	}
+	args ...interface{}) error {
+	return cn.Snapshot().RunFiltered(filter, args...)
 }
 
-func (cn *chainNode) Run(args ...interface{}) {
-	filt := func(interface{}, []interface{}) bool {
-		return true
+func (cn *chainNode) Run(args ...interface{}) error {
+	if d := cn.opts.debounce; d != nil {
+		return d.run(cn, args)
 	}
-	cn.RunFiltered(filt, args...)
+	if f := cn.opts.flight; f != nil {
+		return f.run(cn, args)
+	}
+	return cn.Snapshot().Run(args...)
 }
 
-func iterate(cn *chainNode, W ...*sync.WaitGroup) <-chan CallProxy {
-	C := make(chan CallProxy, len(cn.funcs))
-	if cn.wait != nil {
-		W = append(W, cn.wait)
-	}
-	if len(W) > 0 {
-		addAll(1, W...)
-		defer doneAll(W...)
-	}
-	go func(funcs []CallProxy, c chan<- CallProxy, waits []*sync.WaitGroup) {
-		defer close(c)
-		var fn CallProxy
-		for _, fn = range funcs {
-			if len(waits) > 0 {
-				addAll(1, waits...)
-			}
-			select {
-			case c <- fn:
-			case <-time.After(time.Duration(10) * time.Second):
-				if len(waits) > 0 {
-					doneAll(waits...)
-				}
-				return
-			}
+// Iterate snapshots the node's funcs into a channel buffered to hold all
+// of them, which is closed before being returned (unless the chain was
+// created using WithIterateBuffer). Every waitgroup (including the node's
+// own, if it has an Add(int) method, see WithWaiter) has been incremented
+// once for each func by the time Iterate returns.
+func (cn *chainNode) Iterate(W ...*sync.WaitGroup) <-chan interface{} {
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+
+	addAll(len(cn.funcs), W...)
+	if w, ok := cn.wait.(interface{ Add(int) }); ok {
+		w.Add(len(cn.funcs))
+	}
+	if cn.opts.iterBuffer != IterateBuffered {
+		ids := make([]interface{}, len(cn.funcs))
+		for i, e := range cn.funcs {
+			ids[i] = e.id
 		}
-	}(cn.funcs, C, W)
+		C := make(chan interface{}, cn.opts.iterBuffer)
+		go func() {
+			defer close(C)
+			for _, id := range ids {
+				C <- id
+			}
+		}()
+		return C
+	}
+	C := make(chan interface{}, len(cn.funcs))
+	for _, e := range cn.funcs {
+		C <- e.id
+	}
+	close(C)
 	return C
 }
 
-func (cn *chainNode) Iterate(W ...*sync.WaitGroup) <-chan interface{} {
-	C := make(chan interface{}, 1)
-
-	W = append(W, nil)
-	if len(W) > 1 {
-		copy(W[1:], W[0:])
-	}
-	W[0] = &sync.WaitGroup{}
-	addAll(1, W...)
-	go func(inC <-chan CallProxy, outC chan<- interface{}, waits []*sync.WaitGroup) {
-		defer doneAll(waits...)
-		defer close(outC)
-		for {
-			c, ok := <-inC
-			if !ok {
-				return
-			}
-			if val, ok := c.(reflect.Value); ok {
-				outC <- val.Interface()
-			} else {
-				outC <- c
-			}
-		}
-	}(iterate(cn, W...), C, W)
-	return C
+func (cn *chainNode) FuncsSlice() []interface{} {
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	ids := make([]interface{}, len(cn.funcs))
+	for i, e := range cn.funcs {
+		ids[i] = e.id
+	}
+	return ids
+}
+
+func (root *chainNode) Nodes() []Call {
+	s := root.Snapshot()
+	nodes := make([]Call, len(s.nodes))
+	for i, n := range s.nodes {
+		nodes[i] = n.node
+	}
+	return nodes
 }
 
 // Iterate over the entire callchain list starting with
 // antecdent nodes. See Iterate() for an example of usage.
+// As with Iterate() the channel is already filled and closed (unless
+// WithIterateBuffer is used).
 func (root *chainNode) IterateAll() <-chan Call {
-	C := make(chan Call, 0)
-	go func(cn *chainNode, c chan<- Call) {
-		defer close(c)
-		var cnext *chainNode
-		for ; cn != nil; cn = cnext {
-			cnext = cn.getNext()
-			select {
-			case c <- cn:
-			case <-time.After(time.Duration(10) * time.Second):
-				return
+	s := root.Snapshot()
+	if s.opts.iterBuffer != IterateBuffered {
+		C := make(chan Call, s.opts.iterBuffer)
+		go func() {
+			defer close(C)
+			for _, n := range s.nodes {
+				C <- n.node
 			}
-		}
-	}(root.getFirst(), C)
+		}()
+		return C
+	}
+	C := make(chan Call, len(s.nodes))
+	for _, n := range s.nodes {
+		C <- n.node
+	}
+	close(C)
 	return C
 }
@@ -1,10 +1,17 @@
 package chain_test
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	_ "log"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/jsipprell/go-chain"
 )
@@ -81,14 +88,14 @@ func initChain() {
 	}
 }
 
-func ExampleChain() {
+func Example() {
 	initChain()
 
 	pf := PrintingFunc(func(v ...interface{}) {
 		fmt.Println(v...)
 	})
 	testChain.Run(pf)
-	// Output:
+	// Unordered output:
 	// very first
 	// even more before 1
 	// about the same time as even more before 1
@@ -98,6 +105,71 @@ func ExampleChain() {
 	// very last
 }
 
+func TestChainOrder(t *testing.T) {
+	initChain()
+
+	var lock sync.Mutex
+	var got []string
+	testChain.Run(PrintingFunc(func(v ...interface{}) {
+		lock.Lock()
+		defer lock.Unlock()
+		got = append(got, fmt.Sprint(v...))
+	}))
+
+	// funcs sharing a node may run in any order relative to each other
+	want := [][]string{
+		{"very first"},
+		{"even more before 1", "about the same time as even more before 1"},
+		{"after even more before 1"},
+		{"before 1"},
+		{"startup 1"},
+		{"very last"},
+	}
+	if len(got) != 7 {
+		t.Fatalf("expected 7 calls, got %d: %v", len(got), got)
+	}
+	i := 0
+	for _, node := range want {
+		seen := make(map[string]bool)
+		for _, s := range got[i : i+len(node)] {
+			seen[s] = true
+		}
+		for _, s := range node {
+			if !seen[s] {
+				t.Fatalf("%q ran out of order: %v", s, got)
+			}
+		}
+		i += len(node)
+	}
+}
+
+func TestNodeDelay(t *testing.T) {
+	var lock sync.Mutex
+	var stamps []time.Time
+	mark := func() {
+		lock.Lock()
+		defer lock.Unlock()
+		stamps = append(stamps, time.Now())
+	}
+
+	delay := 20 * time.Millisecond
+	c := chain.New(chain.WithNodeDelay(delay))
+	pred, err := c.Register(mark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pred.After(mark); err != nil {
+		t.Fatal(err)
+	}
+	c.Run()
+	if len(stamps) != 2 {
+		t.Fatalf("expected 2 calls, got %d", len(stamps))
+	}
+	if d := stamps[1].Sub(stamps[0]); d < delay {
+		t.Fatalf("second node released after %v, expected at least %v", d, delay)
+	}
+}
+
 func TestChainLen(t *testing.T) {
 	initChain()
 
@@ -113,6 +185,18 @@ func TestChainLen(t *testing.T) {
 	}))
 }
 
+func TestClone(t *testing.T) {
+	initChain()
+
+	c := testChain.Clone()
+	if l := c.Len(); l != testChain.Len() {
+		t.Fatalf("clone has %d funcs, source has %d", l, testChain.Len())
+	}
+	if changes := chain.Diff(testChain, c); len(changes) != 0 {
+		t.Fatalf("clone differs from its source: %v", changes)
+	}
+}
+
 func TestTypedChain(t *testing.T) {
 	c := chain.NewTyped(TestFunc(nil))
 	_, err := c.Register(func(x *testing.T) { x.Log("success") })
@@ -152,6 +236,27 @@ func TestVariadicTypedChain(t *testing.T) {
 	t.Log("done")
 }
 
+func TestPrefixFuncs(t *testing.T) {
+	var calls []string
+	c := chain.NewTyped(TestVariadicFunc(nil), chain.WithPrefixFuncs())
+	prefix := func(x *testing.T) { calls = append(calls, "prefix") }
+	pred, err := c.Register(prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pred.After(func(x *testing.T, vals ...string) { calls = append(calls, vals...) })
+	if _, err = c.Register(func(int) {}); err == nil {
+		t.Fatal("expected incompatible func to be rejected")
+	}
+	c.Run(t, "a", "b")
+	if !reflect.DeepEqual(calls, []string{"prefix", "a", "b"}) {
+		t.Fatalf("unexpected calls %v", calls)
+	}
+	if _, ok := c.FindFunc(prefix); !ok {
+		t.Fatal("prefix func not found")
+	}
+}
+
 func TestFilter1(t *testing.T) {
 	validation := &chain.ValidationFilter{
 		V: chain.ValidationFunc(func(i ...interface{}) (ok bool, err error) {
@@ -194,3 +299,418 @@ func TestFilter1(t *testing.T) {
 	c.RunFiltered(filter)
 	t.Log("done")
 }
+
+func TestSplice(t *testing.T) {
+	var lock sync.Mutex
+	var got []string
+	mark := func(s string) func(*testing.T) {
+		return func(*testing.T) {
+			lock.Lock()
+			defer lock.Unlock()
+			got = append(got, s)
+		}
+	}
+
+	c := chain.NewTyped(TestFunc(nil))
+	head, err := c.Register(mark("head"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tail, err := head.After(mark("tail"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segment := chain.New()
+	p, err := segment.Register(mark("seg 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.After(mark("seg 2")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = tail.SpliceBefore(segment); err != nil {
+		t.Fatal(err)
+	}
+	if err = tail.SpliceAfter(segment); err != nil {
+		t.Fatal(err)
+	}
+	c.Run(t)
+
+	want := []string{"head", "seg 1", "seg 2", "tail", "seg 1", "seg 2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if l := segment.Len(); l != 2 {
+		t.Fatalf("splicing modified the source chain, length is %d", l)
+	}
+
+	bad := chain.New()
+	if _, err = bad.Register(func(int) {}); err != nil {
+		t.Fatal(err)
+	}
+	if err = tail.SpliceAfter(bad); err == nil {
+		t.Fatal("expected splicing an incompatible func to fail")
+	}
+	if l := c.Len(); l != 6 {
+		t.Fatalf("failed splice changed chain length to %d", l)
+	}
+}
+
+func TestSpliceOptions(t *testing.T) {
+	var ran []string
+	mark := func(s string) func() error {
+		return func() error {
+			ran = append(ran, s)
+			return nil
+		}
+	}
+	failure := errors.New("failure")
+	fail := func(s string) func() error {
+		return func() error {
+			ran = append(ran, s)
+			return failure
+		}
+	}
+
+	segment := chain.New()
+	p, err := segment.Register(mark("skipped"), chain.SkipIf(func([]interface{}) bool { return true }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.Register(mark("once"), chain.MaxRuns(1)); err != nil {
+		t.Fatal(err)
+	}
+	done := func(string) (bool, error) { return true, nil }
+	if _, err = p.Register(mark("done"), chain.Idempotent("key", done)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.Register(fail("recovered"), chain.Recovery(chain.Continue)); err != nil {
+		t.Fatal(err)
+	}
+	q, err := p.After(fail("handled"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = q.OnError(func(chain.FuncInfo, error) chain.Decision { return chain.Continue }); err != nil {
+		t.Fatal(err)
+	}
+	if err = q.SetName("handled"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := chain.New(chain.WithRecovery(chain.StopChain), chain.WithWorkers(1))
+	head, err := c.Register(mark("head"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = head.After(mark("tail")); err != nil {
+		t.Fatal(err)
+	}
+	if err = head.SpliceAfter(segment); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range [][]string{
+		{"head", "once", "recovered", "handled", "tail"},
+		{"head", "recovered", "handled", "tail"},
+	} {
+		ran = nil
+		c.Run()
+		if !reflect.DeepEqual(ran, want) {
+			t.Fatalf("expected %v, got %v", want, ran)
+		}
+	}
+	if nodes, err := c.NodesMatching("handled"); err != nil || len(nodes) != 1 {
+		t.Fatalf("spliced node name lost, matched %d nodes (%v)", len(nodes), err)
+	}
+}
+
+func (tw *TestWrapper) Unwrap() interface{} {
+	return tw.fp
+}
+
+func TestFindFunc(t *testing.T) {
+	closure := func(s string) func(*testing.T) {
+		return func(*testing.T) { _ = s }
+	}
+	a, b := closure("a"), closure("b")
+	c := chain.NewTyped(TestFunc(nil))
+	first, err := c.Register(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := first.After(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p, ok := c.FindFunc(a); !ok || p != first {
+		t.Fatal("did not find first func in first node")
+	}
+	if p, ok := c.FindFunc(b); !ok || p != second {
+		t.Fatal("did not find second func in second node")
+	}
+	if _, ok := c.FindFunc(closure("c")); ok {
+		t.Fatal("found a func that was never registered")
+	}
+
+	inner := func(int) {}
+	v := chain.NewValidating(&chain.ValidationFilter{
+		V: chain.ValidationFunc(func(i ...interface{}) (bool, error) { return true, nil }),
+		F: chain.FilterFunc(func(i ...interface{}) (interface{}, error) {
+			return &TestWrapper{fp: reflect.ValueOf(i[0])}, nil
+		}),
+	})
+	if _, err = v.Register(inner); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.FindFunc(inner); !ok {
+		t.Fatal("did not find func through wrapping CallProxy")
+	}
+}
+
+func TestPosition(t *testing.T) {
+	initChain()
+
+	head := testChain.Head()
+	tail := testChain.Tail()
+	if p := head.Position(); p != 0 {
+		t.Fatalf("head is at position %d", p)
+	}
+	if p := tail.Position(); p != 5 {
+		t.Fatalf("tail is at position %d instead of 5", p)
+	}
+	if d := chain.Distance(head, tail); d != 5 {
+		t.Fatalf("distance from head to tail is %d instead of 5", d)
+	}
+	if d := chain.Distance(tail, head); d != -5 {
+		t.Fatalf("distance from tail to head is %d instead of -5", d)
+	}
+	if d := chain.Distance(tail, tail); d != 0 {
+		t.Fatalf("distance to self is %d", d)
+	}
+}
+
+func TestPartialRuns(t *testing.T) {
+	var got []int
+	c := chain.New()
+	p1, _ := c.Register(func() { got = append(got, 1) })
+	p2, _ := p1.After(func() { got = append(got, 2) })
+	p2.After(func() { got = append(got, 3) })
+
+	c.RunFrom(p2)
+	if !reflect.DeepEqual(got, []int{2, 3}) {
+		t.Fatalf("RunFrom ran %v", got)
+	}
+	got = nil
+	c.RunUntil(p2)
+	if !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Fatalf("RunUntil ran %v", got)
+	}
+}
+
+func TestTypedFilter(t *testing.T) {
+	var ran []string
+	c := chain.New()
+	c.Register(TestFunc(func(*testing.T) { ran = append(ran, "TestFunc") }))
+	c.Register(func(*testing.T) { ran = append(ran, "plain") })
+
+	c.RunFiltered(chain.TypedFilter(func(fn TestFunc, args []interface{}) bool {
+		return len(args) == 1
+	}), t)
+	if !reflect.DeepEqual(ran, []string{"TestFunc"}) {
+		t.Fatalf("typed filter ran %v", ran)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected malformed filter to panic")
+		}
+	}()
+	chain.TypedFilter(func(TestFunc) bool { return true })
+}
+
+func TestRegistrationSite(t *testing.T) {
+	c := chain.NewTyped(TestFunc(nil))
+	_, err := c.Register(func(int) {})
+	if err == nil {
+		t.Fatal("expected incompatible func to be rejected")
+	}
+	rerr, ok := err.(*chain.RegistrationError)
+	if !ok {
+		t.Fatalf("expected a RegistrationError, got %T", err)
+	}
+	if !strings.Contains(rerr.Site, "chain_test.go:") {
+		t.Fatalf("wrong registration site %q", rerr.Site)
+	}
+
+	if _, err = c.Register(func(*testing.T) {}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = c.Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "chain_test.go:") {
+		t.Fatalf("dump does not include registration site:\n%s", buf.String())
+	}
+}
+
+func TestIterate(t *testing.T) {
+	initChain()
+
+	// the synchronization example from the Call documentation
+	var lock sync.Mutex
+	var got []string
+	pf := PrintingFunc(func(v ...interface{}) {
+		lock.Lock()
+		defer lock.Unlock()
+		got = append(got, fmt.Sprint(v...))
+	})
+	var chainWait chain.Waiter = chain.NullWaiter
+	var globalWait *sync.WaitGroup = &sync.WaitGroup{}
+	for callChain := range testChain.IterateAll() {
+		for fn := range callChain.Iterate(globalWait) {
+			go func(f PrintFunc, outerWait chain.Waiter, innerWait *sync.WaitGroup) {
+				defer globalWait.Done()
+				if innerWait != nil {
+					defer innerWait.Done()
+				}
+				outerWait.Wait()
+				f(pf)
+			}(fn.(PrintFunc), chainWait, chain.WaitGroup(callChain))
+		}
+		chainWait = chain.WaitGroup(callChain)
+	}
+	globalWait.Wait()
+	if len(got) != 7 {
+		t.Fatalf("expected 7 calls, got %v", got)
+	}
+}
+
+func TestIterateUnbuffered(t *testing.T) {
+	c := chain.New(chain.WithIterateBuffer(0))
+	p, _ := c.Register(func() {}, func() {})
+	p.After(func() {})
+	nodes, funcs := 0, 0
+	for call := range c.IterateAll() {
+		nodes++
+		for range call.Iterate() {
+			funcs++
+		}
+		call.(chain.Predicate).After(func() {})
+	}
+	// nodes added while iterating aren't seen
+	if nodes != 2 || funcs != 3 {
+		t.Fatalf("expected 2 nodes and 3 funcs, got %d and %d", nodes, funcs)
+	}
+}
+
+func TestNodes(t *testing.T) {
+	c := chain.New()
+	p, _ := c.Register(func() {}, func() {})
+	p.After(func() {})
+	nodes := c.Nodes()
+	if len(nodes) != 2 || len(nodes[0].FuncsSlice()) != 2 || len(nodes[1].FuncsSlice()) != 1 {
+		t.Fatalf("unexpected layout %v", nodes)
+	}
+	// no waitgroups were touched, so waiting doesn't block
+	chain.WaitGroup(nodes[0]).Wait()
+}
+
+type component struct {
+	lock  sync.Mutex
+	calls []string
+}
+
+func (c *component) record(s string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.calls = append(c.calls, s)
+}
+
+func (c *component) InitDB(*testing.T)      { c.record("InitDB") }
+func (c *component) InitCache(*testing.T)   { c.record("InitCache") }
+func (c *component) Shutdown(*testing.T)    { c.record("Shutdown") }
+func (c *component) InitOther(int)          { c.record("InitOther") }
+func (c *component) Name(*testing.T) string { return "component" }
+
+func TestRegisterMethods(t *testing.T) {
+	comp := &component{}
+	c := chain.NewTyped(TestFunc(nil))
+	n, err := c.RegisterMethods(comp, "Init")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 methods to be registered, got %d", n)
+	}
+	c.Run(t)
+	sort.Strings(comp.calls)
+	if !reflect.DeepEqual(comp.calls, []string{"InitCache", "InitDB"}) {
+		t.Fatalf("wrong methods called: %v", comp.calls)
+	}
+
+	c = chain.NewTyped(TestFunc(nil))
+	if n, err = c.RegisterMethods(comp); err != nil || n != 3 {
+		t.Fatalf("expected 3 methods registered without a prefix, got %d (%v)", n, err)
+	}
+}
+
+func TestRegisterSeq(t *testing.T) {
+	var got []int
+	c := chain.NewTyped(TestFunc(nil))
+	head, err := c.Register(func(*testing.T) { got = append(got, 0) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, err := head.RegisterSeq(
+		func(*testing.T) { got = append(got, 1) },
+		func(*testing.T) { got = append(got, 2) },
+		func(*testing.T) { got = append(got, 3) },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := last.Position(); p != 3 {
+		t.Fatalf("last node of sequence at position %d", p)
+	}
+	c.Run(t)
+	if !reflect.DeepEqual(got, []int{0, 1, 2, 3}) {
+		t.Fatalf("sequence ran as %v", got)
+	}
+
+	if _, err = head.RegisterSeq(func(*testing.T) {}, func(int) {}); err == nil {
+		t.Fatal("expected incompatible func to be rejected")
+	}
+	if l := c.Len(); l != 4 {
+		t.Fatalf("failed sequence changed chain length to %d", l)
+	}
+}
+
+func TestRegisterMultiple(t *testing.T) {
+	var calls int32
+	inc := func(*testing.T) { atomic.AddInt32(&calls, 1) }
+	c := chain.NewTyped(TestFunc(nil))
+	if _, err := c.Register(inc, inc, inc); err != nil {
+		t.Fatal(err)
+	}
+	if l := c.Len(); l != 3 {
+		t.Fatalf("expected 3 funcs registered, got %d", l)
+	}
+	c.Run(t)
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+
+	_, err := c.Register(inc, func(int) {}, inc)
+	var argErr *chain.ArgumentError
+	if !errors.As(err, &argErr) || argErr.Index != 1 {
+		t.Fatalf("expected failure of second argument to be identified, got %v", err)
+	}
+	if l := c.Len(); l != 3 {
+		t.Fatalf("failed registration changed chain length to %d", l)
+	}
+}
@@ -0,0 +1,259 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+// Command chaingen generates a strongly typed call chain for a named func
+// type. Given
+//
+//	//go:generate go run github.com/jsipprell/go-chain/chaingen -type StartFunc
+//	type StartFunc func(ctx context.Context, cfg *Config) error
+//
+// it writes startfunc_chain.go declaring StartFuncChain, which embeds a
+// chain.Root and adds RegisterStartFunc() and RunStartFunc() methods with
+// the func type's exact signature, along with StartFuncRunner() for use
+// with the Predicate methods. Funcs are registered as chain.Runners which
+// runs call without any reflection.
+//
+// The func type must return nothing or a single error.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const chainImport = "github.com/jsipprell/go-chain"
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("chaingen: ")
+	typeName := flag.String("type", "", "name of the func type to generate a chain for")
+	output := flag.String("output", "", "output file (default <type>_chain.go)")
+	flag.Parse()
+	if *typeName == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			src, err := generate(fset, file, *typeName)
+			if err == errNotFound {
+				continue
+			} else if err != nil {
+				log.Fatal(err)
+			}
+			name := *output
+			if name == "" {
+				name = filepath.Join(dir, strings.ToLower(*typeName)+"_chain.go")
+			}
+			if err = os.WriteFile(name, src, 0644); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+	log.Fatalf("func type %s not found in %s", *typeName, dir)
+}
+
+var errNotFound = errors.New("not found")
+
+// generate returns the source of the chain for the func type typeName
+// declared in file, or errNotFound.
+func generate(fset *token.FileSet, file *ast.File, typeName string) ([]byte, error) {
+	ft := findFuncType(file, typeName)
+	if ft == nil {
+		return nil, errNotFound
+	}
+	switch {
+	case ft.Results == nil || len(ft.Results.List) == 0:
+	case len(ft.Results.List) == 1 && len(ft.Results.List[0].Names) <= 1 && isIdent(ft.Results.List[0].Type, "error"):
+	default:
+		return nil, fmt.Errorf("%s must return nothing or error", typeName)
+	}
+	returnsErr := ft.Results != nil && len(ft.Results.List) > 0
+
+	expr := func(e ast.Expr) string {
+		var buf bytes.Buffer
+		printer.Fprint(&buf, fset, e)
+		return buf.String()
+	}
+
+	// one parameter per name, unnamed parameters count once
+	var types []string
+	variadic := false
+	for _, f := range ft.Params.List {
+		n := len(f.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			if ell, ok := f.Type.(*ast.Ellipsis); ok {
+				variadic = true
+				types = append(types, expr(ell.Elt))
+			} else {
+				types = append(types, expr(f.Type))
+			}
+		}
+	}
+	fixed := len(types)
+	if variadic {
+		fixed--
+	}
+
+	var params, args, conv, call []string
+	for i, T := range types {
+		a := "a" + strconv.Itoa(i)
+		if i == fixed {
+			params = append(params, a+" ..."+T)
+			conv = append(conv, fmt.Sprintf("%s := make([]%s, len(args)-%d)\nfor i := range %s {\n%s[i], _ = args[%d+i].(%s)\n}", a, T, fixed, a, a, fixed, T))
+			call = append(call, a+"...")
+			continue
+		}
+		params = append(params, a+" "+T)
+		args = append(args, a)
+		conv = append(conv, fmt.Sprintf("%s, _ := args[%d].(%s)", a, i, T))
+		call = append(call, a)
+	}
+
+	var b bytes.Buffer
+	p := func(format string, a ...interface{}) { fmt.Fprintf(&b, format, a...) }
+	runner := lowerFirst(typeName) + "Runner"
+	p("// Code generated by chaingen -type %s. DO NOT EDIT.\n\n", typeName)
+	p("package %s\n\n", file.Name.Name)
+	p("import (\n")
+	for _, imp := range usedImports(file, ft) {
+		p("%s\n", imp)
+	}
+	p("%q\n)\n\n", chainImport)
+
+	p("// %sChain is a call chain of %s funcs.\n", typeName, typeName)
+	p("type %sChain struct {\nchain.Root\n}\n\n", typeName)
+	p("// New%sChain returns a new, empty %sChain.\n", typeName, typeName)
+	p("func New%sChain(opts ...chain.Option) *%sChain {\nreturn &%sChain{chain.New(opts...)}\n}\n\n", typeName, typeName, typeName)
+
+	p("// Register%s registers funcs with the chain's root node.\n", typeName)
+	p("func (c *%sChain) Register%s(fn ...%s) (chain.Predicate, error) {\n", typeName, typeName, typeName)
+	p("r := make([]interface{}, len(fn))\nfor i, f := range fn {\nr[i] = %s{f}\n}\nreturn c.Root.Register(r...)\n}\n\n", runner)
+
+	p("// Run%s runs the chain.\n", typeName)
+	p("func (c *%sChain) Run%s(%s) error {\n", typeName, typeName, strings.Join(params, ", "))
+	if variadic {
+		va := "a" + strconv.Itoa(fixed)
+		p("args := make([]interface{}, 0, %d+len(%s))\n", fixed, va)
+		if fixed > 0 {
+			p("args = append(args, %s)\n", strings.Join(args, ", "))
+		}
+		p("for _, v := range %s {\nargs = append(args, v)\n}\nreturn c.Root.Run(args...)\n}\n\n", va)
+	} else {
+		p("return c.Root.Run(%s)\n}\n\n", strings.Join(args, ", "))
+	}
+
+	p("// %sRunner returns fn in a form that can be registered with the\n", typeName)
+	p("// Predicate methods of a %sChain.\n", typeName)
+	p("func %sRunner(fn %s) interface{} {\nreturn %s{fn}\n}\n\n", typeName, typeName, runner)
+
+	// NB: the runner must not be a func or it would be called by reflection
+	p("type %s struct {\nfn %s\n}\n\n", runner, typeName)
+	p("func (r %s) Run(args ...interface{}) error {\n", runner)
+	p("%s\n", strings.Join(conv, "\n"))
+	if returnsErr {
+		p("return r.fn(%s)\n}\n", strings.Join(call, ", "))
+	} else {
+		p("r.fn(%s)\nreturn nil\n}\n", strings.Join(call, ", "))
+	}
+	return format.Source(b.Bytes())
+}
+
+func findFuncType(file *ast.File, name string) *ast.FuncType {
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			if ts := spec.(*ast.TypeSpec); ts.Name.Name == name {
+				ft, _ := ts.Type.(*ast.FuncType)
+				return ft
+			}
+		}
+	}
+	return nil
+}
+
+// returns the import specs of file used by the func type, assuming that
+// packages are named after the last element of their import path unless
+// renamed
+func usedImports(file *ast.File, ft *ast.FuncType) (imports []string) {
+	used := make(map[string]bool)
+	ast.Inspect(ft, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+	for _, imp := range file.Imports {
+		path, _ := strconv.Unquote(imp.Path.Value)
+		if path == chainImport {
+			continue
+		}
+		name := path[strings.LastIndex(path, "/")+1:]
+		if imp.Name != nil {
+			name = imp.Name.Name
+		}
+		if used[name] {
+			if imp.Name != nil {
+				imports = append(imports, imp.Name.Name+" "+imp.Path.Value)
+			} else {
+				imports = append(imports, imp.Path.Value)
+			}
+		}
+	}
+	return
+}
+
+func isIdent(e ast.Expr, name string) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == name
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const testSource = `package app
+
+import (
+	"context"
+	"io"
+	unused "net/http"
+)
+
+type StartFunc func(ctx context.Context, name string, w ...io.Writer) error
+
+var _ = unused.StatusOK
+`
+
+func TestGenerate(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "app.go", testSource, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate(fset, file, "StartFunc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen, err := parser.ParseFile(token.NewFileSet(), "startfunc_chain.go", src, 0)
+	if err != nil {
+		t.Fatalf("generated source doesn't parse: %v\n%s", err, src)
+	}
+	if len(gen.Imports) != 3 {
+		t.Fatalf("expected only the used imports and chain, got\n%s", src)
+	}
+	for _, want := range []string{
+		"func NewStartFuncChain(opts ...chain.Option) *StartFuncChain",
+		"func (c *StartFuncChain) RegisterStartFunc(fn ...StartFunc) (chain.Predicate, error)",
+		"func (c *StartFuncChain) RunStartFunc(a0 context.Context, a1 string, a2 ...io.Writer) error",
+		"return r.fn(a0, a1, a2...)",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated source has no %q:\n%s", want, src)
+		}
+	}
+
+	if _, err = generate(fset, file, "Missing"); err != errNotFound {
+		t.Fatalf("expected errNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+// Package chaintest provides helpers for using call chains in tests.
+package chaintest
+
+import (
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+// New returns a chain for use by a single test. Any error returned by a
+// synchronous run of the chain (Run() and friends) is reported as a test
+// error.
+func New(t testing.TB, opts ...chain.Option) chain.Root {
+	opts = append(opts, chain.WithErrorHandler(func(err error) {
+		t.Helper()
+		t.Errorf("chain: %v", err)
+	}))
+	return chain.New(opts...)
+}
+
+// Cleanup arranges for root to be run with args when the test and its
+// subtests complete (see testing.T.Cleanup), so that teardown can be
+// expressed as an ordered chain. Funcs registered after Cleanup() is
+// called still run. Any error is reported as a test error.
+func Cleanup(t testing.TB, root chain.Root, args ...interface{}) {
+	t.Cleanup(func() {
+		run(t, root.Snapshot(), args)
+	})
+}
+
+// CleanupReverse is like Cleanup but runs the chain's nodes in reverse
+// order, so that the same chain can describe both setup and teardown.
+func CleanupReverse(t testing.TB, root chain.Root, args ...interface{}) {
+	t.Cleanup(func() {
+		run(t, root.Snapshot().Reverse(), args)
+	})
+}
+
+func run(t testing.TB, s *chain.Snapshot, args []interface{}) {
+	t.Helper()
+	if err := s.Start(args...).Err(); err != nil {
+		t.Errorf("chain cleanup: %v", err)
+	}
+}
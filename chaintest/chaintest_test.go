@@ -0,0 +1,26 @@
+package chaintest_test
+
+import (
+	"testing"
+
+	"github.com/jsipprell/go-chain/chaintest"
+)
+
+func TestCleanup(t *testing.T) {
+	var got []string
+	t.Run("sub", func(t *testing.T) {
+		c := chaintest.New(t)
+		first, err := c.Register(func() { got = append(got, "first") })
+		if err != nil {
+			t.Fatal(err)
+		}
+		chaintest.CleanupReverse(t, c)
+		// registered after the cleanup, still runs
+		if _, err = first.After(func() { got = append(got, "second") }); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if len(got) != 2 || got[0] != "second" || got[1] != "first" {
+		t.Fatalf("unexpected cleanup order %v", got)
+	}
+}
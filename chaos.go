@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Chaos configures WithChaos.
+type Chaos struct {
+	// Seed determines the dispatch order and delays of every run. Zero
+	// picks a new seed for each run, which can be recovered with
+	// Execution.Seed() to reproduce the run's order.
+	Seed int64
+	// Weight, if set, biases the order so that funcs with greater weights
+	// tend to be dispatched sooner. Non-positive weights count as 1.
+	Weight func(FuncInfo) float64
+	// MaxDelay, if positive, delays each call by a random duration of up
+	// to MaxDelay.
+	MaxDelay time.Duration
+}
+
+// WithChaos randomizes the order in which the funcs of each node are
+// dispatched, and optionally delays them, to shake out hidden ordering
+// assumptions between funcs registered as order-independent. Unlike
+// WithDeterministic, which takes precedence, funcs still run concurrently
+// and so can still rendezvous with each other.
+func WithChaos(c Chaos) Option {
+	return func(o *options) {
+		o.chaos = &c
+	}
+}
+
+// Seed returns the seed which determined the run's dispatch order, see
+// WithDeterministic and WithChaos. It is zero for other runs.
+func (e *Execution) Seed() int64 {
+	return e.seed
+}
+
+// returns the source of a run's dispatch order, rng for deterministic runs
+// and chaos for chaos runs; at most one is set
+func (e *Execution) shufflers() (rng, chaos *rand.Rand) {
+	if rng = e.snap.opts.shuffler(); rng != nil {
+		e.seed = e.snap.opts.seed
+		return rng, nil
+	}
+	return nil, e.chaos()
+}
+
+func (e *Execution) chaos() *rand.Rand {
+	c := e.snap.opts.chaos
+	if c == nil {
+		return nil
+	}
+	e.seed = c.Seed
+	for e.seed == 0 {
+		e.seed = time.Now().UnixNano()
+	}
+	return rand.New(rand.NewSource(e.seed))
+}
+
+// reorders a phase's calls by weighted random sampling and picks their
+// delays
+func (e *Execution) disorder(rng *rand.Rand, p *phase) {
+	c := e.snap.opts.chaos
+	keys := make([]float64, len(p.calls))
+	for i, ent := range p.calls {
+		w := 1.0
+		if c.Weight != nil {
+			if w = c.Weight(ent.info(p.node)); w <= 0 {
+				w = 1
+			}
+		}
+		// NB: larger keys go first, uniformly random for equal weights
+		keys[i] = math.Log(1-rng.Float64()) / w
+	}
+	sort.Stable(byKey{p, keys})
+	if c.MaxDelay > 0 {
+		p.delays = make([]time.Duration, len(p.calls))
+		for i := range p.delays {
+			p.delays[i] = time.Duration(rng.Int63n(int64(c.MaxDelay) + 1))
+		}
+	}
+}
+
+// sorts a phase's calls by descending key
+type byKey struct {
+	p    *phase
+	keys []float64
+}
+
+func (b byKey) Len() int           { return len(b.keys) }
+func (b byKey) Less(i, j int) bool { return b.keys[i] > b.keys[j] }
+func (b byKey) Swap(i, j int) {
+	b.keys[i], b.keys[j] = b.keys[j], b.keys[i]
+	b.p.swap(i, j)
+}
+
+// sleeps off a call's injected delay, unless the run is stopped first
+func (e *Execution) delay(c call) {
+	if c.delays == nil || c.delays[c.index] <= 0 {
+		return
+	}
+	t := time.NewTimer(c.delays[c.index])
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-e.halted:
+	}
+}
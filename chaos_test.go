@@ -0,0 +1,72 @@
+package chain_test
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestChaos(t *testing.T) {
+	order := func(c chain.Chaos) ([]int, int64) {
+		var lock sync.Mutex
+		var got []int
+		ch := chain.New(chain.WithWorkers(1), chain.WithChaos(c))
+		for i := 0; i < 8; i++ {
+			i := i
+			ch.Register(func() {
+				lock.Lock()
+				defer lock.Unlock()
+				got = append(got, i)
+			})
+		}
+		e := ch.Start()
+		if err := e.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return got, e.Seed()
+	}
+	first, seed := order(chain.Chaos{})
+	if seed == 0 {
+		t.Fatal("run has no seed")
+	}
+	if again, _ := order(chain.Chaos{Seed: seed}); !reflect.DeepEqual(first, again) {
+		t.Errorf("seed %d gave %v then %v", seed, first, again)
+	}
+	orders := make(map[string]bool)
+	for seed := int64(1); seed <= 10; seed++ {
+		got, _ := order(chain.Chaos{Seed: seed, MaxDelay: time.Millisecond})
+		orders[fmt.Sprint(got)] = true
+	}
+	if len(orders) < 2 {
+		t.Error("seed has no effect on order")
+	}
+
+	var ran []string
+	c := chain.New(chain.WithWorkers(1), chain.WithChaos(chain.Chaos{
+		Seed: 1,
+		Weight: func(fn chain.FuncInfo) float64 {
+			if fn.Name == "heavy" {
+				return 1e9
+			}
+			return 1
+		},
+	}))
+	funcs := make(map[string]interface{})
+	for _, name := range []string{"a", "b", "c", "heavy", "d"} {
+		name := name
+		funcs[name] = func() { ran = append(ran, name) }
+	}
+	if _, err := c.Head().RegisterMap(funcs); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 5 || ran[0] != "heavy" {
+		t.Errorf("weighted run called %v", ran)
+	}
+}
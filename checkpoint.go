@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+// CheckpointStore persists which nodes of a chain have completed so that a
+// run interrupted by a crash can be resumed where it left off. Nodes are
+// identified by name (see Predicate.SetName), unnamed nodes are never
+// checkpointed. Implementations must be safe for concurrent use if the
+// chain is run concurrently.
+type CheckpointStore interface {
+	// Completed reports whether the named node has already completed.
+	Completed(node string) (bool, error)
+	// Complete records that the named node has completed.
+	Complete(node string) error
+}
+
+// WithCheckpoints reports every named node whose funcs all run without
+// error to store; nodes some of whose funcs weren't run, such as by
+// RunFiltered, are not reported. Resume() consults store to skip nodes that have already
+// completed; Run() and friends always run every node.
+func WithCheckpoints(store CheckpointStore) Option {
+	return func(o *options) {
+		o.checkpoints = store
+	}
+}
+
+func (cn *chainNode) Resume(args ...interface{}) error {
+	return cn.Snapshot().Resume(args...)
+}
+
+func (cn *chainNode) StartResume(args ...interface{}) *Execution {
+	return cn.Snapshot().StartResume(args...)
+}
+
+// Resume runs the snapshot exactly as Root.Resume() would.
+func (s *Snapshot) Resume(args ...interface{}) error {
+	return s.opts.handle(s.StartResume(args...).Err())
+}
+
+// StartResume is the asynchronous form of Resume.
+func (s *Snapshot) StartResume(args ...interface{}) *Execution {
+	return s.start(&Execution{resuming: true}, nil, args)
+}
+
+func (s *Snapshot) checkpointed(n snapNode) (bool, error) {
+	if s.opts.checkpoints == nil || n.name == "" {
+		return false, nil
+	}
+	return s.opts.checkpoints.Completed(n.name)
+}
+
+// records a completed phase with the checkpoint store provided every func
+// of its node was dispatched by this run, called and succeeded; nodes
+// partially run by RunFiltered and friends are left for a later run.
+func (e *Execution) checkpoint(p *phase) {
+	store := e.snap.opts.checkpoints
+	name := e.snap.nodes[p.node].name
+	if store == nil || name == "" || !e.clean(p) {
+		return
+	}
+	if err := store.Complete(name); err != nil {
+		e.fail(call{phase: p}, err)
+	}
+}
@@ -0,0 +1,114 @@
+package chain_test
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+type memStore struct {
+	lock sync.Mutex
+	done map[string]bool
+}
+
+func (m *memStore) Completed(node string) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.done[node], nil
+}
+
+func (m *memStore) Complete(node string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.done[node] = true
+	return nil
+}
+
+func TestResume(t *testing.T) {
+	store := &memStore{done: make(map[string]bool)}
+	c := chain.New(chain.WithCheckpoints(store), chain.WithStopOnError())
+	failure := errors.New("crash")
+	var ran []string
+	fail := true
+
+	first, err := c.Register(func() { ran = append(ran, "first") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := first.After(func() error {
+		ran = append(ran, "second")
+		if fail {
+			return failure
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	third, err := second.After(func() { ran = append(ran, "third") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, p := range map[string]chain.Predicate{"first": first, "second": second, "third": third} {
+		if err = p.SetName(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = c.Resume(); err != failure {
+		t.Fatalf("expected %v, got %v", failure, err)
+	}
+	if !store.done["first"] || store.done["second"] || store.done["third"] {
+		t.Fatalf("unexpected checkpoints %v", store.done)
+	}
+
+	fail = false
+	ran = nil
+	if err = c.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 2 || ran[0] != "second" || ran[1] != "third" {
+		t.Fatalf("resume ran %v", ran)
+	}
+
+	ran = nil
+	if err = c.Run(); err != nil || len(ran) != 3 {
+		t.Fatalf("Run should ignore checkpoints, ran %v (%v)", ran, err)
+	}
+}
+
+func TestCheckpointFiltered(t *testing.T) {
+	store := &memStore{done: make(map[string]bool)}
+	c := chain.New(chain.WithCheckpoints(store))
+	var ran []string
+	a := func() { ran = append(ran, "a") }
+	b := func() { ran = append(ran, "b") }
+	p, err := c.Register(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.SetName("both"); err != nil {
+		t.Fatal(err)
+	}
+
+	onlyA := func(fn interface{}, _ []interface{}) bool {
+		return reflect.ValueOf(fn).Pointer() == reflect.ValueOf(a).Pointer()
+	}
+	if err = c.RunFiltered(onlyA); err != nil {
+		t.Fatal(err)
+	}
+	if store.done["both"] {
+		t.Fatal("node checkpointed although b was filtered out")
+	}
+
+	ran = nil
+	if err = c.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 2 || !store.done["both"] {
+		t.Fatalf("resume ran %v, checkpoints %v", ran, store.done)
+	}
+}
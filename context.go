@@ -0,0 +1,66 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"context"
+	"reflect"
+)
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// reports whether a func type expects a context.Context as its first
+// parameter
+func takesContext(T reflect.Type) bool {
+	return T != nil && T.Kind() == reflect.Func && T.NumIn() > 0 && T.In(0) == contextType
+}
+
+func (cn *chainNode) RunContext(ctx context.Context, args ...interface{}) error {
+	return cn.Snapshot().RunContext(ctx, args...)
+}
+
+func (cn *chainNode) StartContext(ctx context.Context, args ...interface{}) *Execution {
+	return cn.Snapshot().StartContext(ctx, args...)
+}
+
+// returns the arguments for a call of a func which takes a context, with
+// the context of its phase (and its own output stream, if any)
+func (e *Execution) withContext(c call, in []reflect.Value) []reflect.Value {
+	ctx := c.nodeCtx
+	if c.output != nil {
+		out := &outputBuffer{}
+		e.lock.Lock()
+		c.output[c.index] = out
+		e.lock.Unlock()
+		ctx = context.WithValue(ctx, outputKey{}, out)
+	}
+	// untyped chains may mix funcs that do and don't want the context
+	if e.snap.ftype == nil {
+		return append([]reflect.Value{reflect.ValueOf(ctx)}, in...)
+	}
+	args := make([]reflect.Value, len(in))
+	copy(args, in)
+	args[0] = reflect.ValueOf(ctx)
+	return args
+}
+
+// RunContext runs the snapshot exactly as Root.RunContext() would.
+func (s *Snapshot) RunContext(ctx context.Context, args ...interface{}) error {
+	return s.opts.handle(s.StartContext(ctx, args...).Err())
+}
+
+// StartContext is the asynchronous form of RunContext.
+func (s *Snapshot) StartContext(ctx context.Context, args ...interface{}) *Execution {
+	return s.start(&Execution{ctx: ctx}, nil, args)
+}
@@ -0,0 +1,68 @@
+package chain_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+type ContextFunc func(context.Context, int)
+
+type ctxKey struct{}
+
+func TestRunContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "value"))
+	defer cancel()
+
+	c := chain.NewTyped(ContextFunc(nil))
+	var got []int
+	pred, err := c.Register(func(ctx context.Context, i int) {
+		if ctx.Value(ctxKey{}) != "value" {
+			t.Errorf("func was not passed the run's context")
+		}
+		got = append(got, i)
+		cancel()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pred.After(func(context.Context, int) {
+		t.Error("node after cancellation should not run")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	e := c.StartContext(ctx, 42)
+	if err = e.Err(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+	if len(got) != 1 || got[0] != 42 {
+		t.Fatalf("unexpected args %v", got)
+	}
+	if s := e.Stats(); s.Nodes[1].Skipped != 1 {
+		t.Fatalf("expected 1 skipped func, got %d", s.Nodes[1].Skipped)
+	}
+}
+
+func TestRunContextMixed(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey{}, "value")
+	c := chain.New()
+	var plain, aware bool
+	pred, err := c.Register(func(s string) { plain = s == "arg" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pred.Register(func(ctx context.Context, s string) {
+		aware = s == "arg" && ctx.Value(ctxKey{}) == "value"
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.RunContext(ctx, "arg"); err != nil {
+		t.Fatal(err)
+	}
+	if !plain || !aware {
+		t.Fatalf("plain func ran: %v, context func ran: %v", plain, aware)
+	}
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"sync"
+	"time"
+)
+
+// debouncer coalesces calls to Run() made within its window of each other
+type debouncer struct {
+	window  time.Duration
+	lock    sync.Mutex
+	pending *coalesced
+}
+
+// coalesced is a single run shared by several calls to Run()
+type coalesced struct {
+	args  []interface{}
+	timer *time.Timer
+	done  chan struct{}
+	err   error
+}
+
+// returns a debouncer with the same window, used when cloning chains
+func (d *debouncer) fresh() *debouncer {
+	if d == nil {
+		return nil
+	}
+	return &debouncer{window: d.window}
+}
+
+func (d *debouncer) run(cn *chainNode, args []interface{}) error {
+	d.lock.Lock()
+	c := d.pending
+	if c == nil {
+		c = &coalesced{done: make(chan struct{})}
+		d.pending = c
+		c.timer = time.AfterFunc(d.window, func() { d.fire(cn, c) })
+	} else {
+		c.timer.Reset(d.window)
+	}
+	c.args = args
+	d.lock.Unlock()
+
+	<-c.done
+	return c.err
+}
+
+func (d *debouncer) fire(cn *chainNode, c *coalesced) {
+	d.lock.Lock()
+	// a Reset() racing with the timer can fire it twice
+	if d.pending != c {
+		d.lock.Unlock()
+		return
+	}
+	d.pending = nil
+	args := c.args
+	d.lock.Unlock()
+
+	c.err = cn.Snapshot().Run(args...)
+	close(c.done)
+}
@@ -0,0 +1,43 @@
+package chain_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestDebounce(t *testing.T) {
+	failure := errors.New("failure")
+	var runs int32
+	c := chain.New(chain.WithDebounce(20 * time.Millisecond))
+	if _, err := c.Register(func(int) error {
+		atomic.AddInt32(&runs, 1)
+		return failure
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = c.Run(i)
+		}(i)
+		time.Sleep(time.Millisecond)
+	}
+	wg.Wait()
+	if runs != 1 {
+		t.Fatalf("expected a single run, got %d", runs)
+	}
+	for i, err := range errs {
+		if err != failure {
+			t.Fatalf("call %d: expected %v, got %v", i, failure, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"sync/atomic"
+)
+
+// Decision is returned by node error handlers (see Predicate.OnError) to
+// decide how a run proceeds after a func fails.
+type Decision int
+
+const (
+	// Carry on as if nothing happened.
+	Continue Decision = iota
+	// Skip the node's funcs which haven't started yet, later nodes still
+	// run.
+	StopNode
+	// Skip every func which hasn't started yet, in this node and all
+	// later ones.
+	StopChain
+	// Skip just the func being decided on, see Root.RunSelect(). Error
+	// handlers returning Skip are treated as returning Continue as the
+	// failed func has already run.
+	Skip
+)
+
+func (d Decision) String() string {
+	switch d {
+	case Continue:
+		return "Continue"
+	case StopNode:
+		return "StopNode"
+	case StopChain:
+		return "StopChain"
+	case Skip:
+		return "Skip"
+	}
+	return "Decision(?)"
+}
+
+// RecoveryPolicy is a fixed Decision applied to every failure within its
+// scope: a whole chain (WithRecovery), a node (Predicate.SetRecovery) or a
+// single registration (Recovery). The most specific policy, or node error
+// handler, applies.
+type RecoveryPolicy = Decision
+
+// WithRecovery sets the policy for failures in nodes with neither an error
+// handler nor a policy of their own. The default is Continue.
+func WithRecovery(p RecoveryPolicy) Option {
+	return func(o *options) {
+		o.recovery = p
+	}
+}
+
+// Recovery sets the policy for failures of the funcs registered, which
+// takes precedence over that of their node and chain.
+func Recovery(p RecoveryPolicy) RegisterOption {
+	return func(r *registration) {
+		r.recovery = &p
+	}
+}
+
+func (cn *chainNode) SetRecovery(p RecoveryPolicy) error {
+	return cn.OnError(func(FuncInfo, error) Decision { return p })
+}
+
+func (cn *chainNode) OnError(fn func(FuncInfo, error) Decision) error {
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	if err := cn.checkSealed(); err != nil {
+		return err
+	}
+	cn.onError = fn
+	cn.opts.cache.invalidate()
+	return nil
+}
+
+// applies the most specific recovery policy or error handler after a func
+// fails
+func (e *Execution) decide(c call, err error) {
+	d := e.snap.opts.recovery
+	if c.recovery != nil {
+		d = *c.recovery
+	} else if h := e.snap.nodes[c.node].onError; h != nil {
+		d = h(c.info(c.node), err)
+	}
+	switch d {
+	case StopNode:
+		atomic.StoreInt32(&c.phase.stopped, 1)
+	case StopChain:
+		atomic.StoreInt32(&e.aborted, 1)
+		e.stop()
+	}
+}
+
+// reports whether a func should be skipped because of an earlier failure
+func (e *Execution) stopped(c call) bool {
+	return atomic.LoadInt32(&e.aborted) != 0 ||
+		atomic.LoadInt32(&c.phase.stopped) != 0
+}
+
+// stops a phase about to be released if an earlier one failed and the
+// chain was created WithStopOnError; failures within the phase itself
+// leave the rest of it alone
+func (e *Execution) stopAfterFailure(p *phase) {
+	if e.snap.opts.stopOnError && e.failed() {
+		atomic.StoreInt32(&p.stopped, 1)
+	}
+}
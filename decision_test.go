@@ -0,0 +1,94 @@
+package chain_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestOnError(t *testing.T) {
+	failure := errors.New("failure")
+	for _, tc := range []struct {
+		decision chain.Decision
+		ran      []string
+	}{
+		{chain.Continue, []string{"fail", "same node", "next node"}},
+		{chain.StopNode, []string{"fail", "next node"}},
+		{chain.StopChain, []string{"fail"}},
+	} {
+		var ran []string
+		record := func(name string) func() error {
+			return func() error {
+				ran = append(ran, name)
+				return nil
+			}
+		}
+		c := chain.New(chain.WithWorkers(1))
+		pred, err := c.Register(func() error {
+			ran = append(ran, "fail")
+			return failure
+		}, record("same node"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = pred.After(record("next node")); err != nil {
+			t.Fatal(err)
+		}
+		var got error
+		if err = pred.OnError(func(fn chain.FuncInfo, err error) chain.Decision {
+			got = err
+			return tc.decision
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err = c.Run(); err != failure || got != failure {
+			t.Fatalf("%v: expected %v, got %v (handler got %v)", tc.decision, failure, err, got)
+		}
+		if len(ran) != len(tc.ran) {
+			t.Fatalf("%v: expected %v, got %v", tc.decision, tc.ran, ran)
+		}
+		for i := range ran {
+			if ran[i] != tc.ran[i] {
+				t.Fatalf("%v: expected %v, got %v", tc.decision, tc.ran, ran)
+			}
+		}
+	}
+}
+
+func TestRecoveryPolicy(t *testing.T) {
+	failure := errors.New("failure")
+	ran := 0
+	c := chain.New(chain.WithRecovery(chain.StopChain), chain.WithWorkers(1))
+	pred, err := c.Register(func() error { return failure }, chain.Recovery(chain.Continue))
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := pred.After(func() error {
+		ran++
+		return failure
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = next.After(func() { ran++ }); err != nil {
+		t.Fatal(err)
+	}
+
+	// the registration policy lets the chain continue past the first
+	// failure but the chain's policy stops it at the second
+	c.Run()
+	if ran != 1 {
+		t.Fatalf("expected 1 func to run, got %d", ran)
+	}
+
+	ran = 0
+	if err = next.SetRecovery(chain.Continue); err != nil {
+		t.Fatal(err)
+	}
+	c.Run()
+	if ran != 2 {
+		t.Fatalf("node policy should override the chain's, %d funcs ran", ran)
+	}
+}
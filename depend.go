@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// dependency is a named node in another chain that a node waits for
+type dependency struct {
+	latches *latchSet
+	name    string
+}
+
+// latchSet holds a channel per node name which is closed the first time
+// a node by that name completes.
+type latchSet struct {
+	lock sync.Mutex
+	m    map[string]chan struct{}
+}
+
+func newLatchSet() *latchSet {
+	return &latchSet{m: make(map[string]chan struct{})}
+}
+
+func (l *latchSet) get(name string) chan struct{} {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	ch, ok := l.m[name]
+	if !ok {
+		ch = make(chan struct{})
+		l.m[name] = ch
+	}
+	return ch
+}
+
+func (l *latchSet) signal(name string) {
+	ch := l.get(name)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	select {
+	case <-ch:
+	default:
+		close(ch)
+	}
+}
+
+func (cn *chainNode) WaitFor(other Root, name string) error {
+	o, ok := asNode(other)
+	if !ok {
+		return ErrChainInvalidType
+	}
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	if err := cn.checkSealed(); err != nil {
+		return err
+	}
+	cn.deps = append(cn.deps, dependency{latches: o.opts.latches, name: name})
+	cn.opts.cache.invalidate()
+	return nil
+}
+
+// marks a node of the run's snapshot as completed if it's named
+func (e *Execution) signal(node int) {
+	if n := e.snap.nodes[node]; n.name != "" {
+		e.snap.opts.latches.signal(n.name)
+	}
+}
+
+// reports whether every func of a completed phase's node was dispatched
+// by the run and finished without failing or being skipped, canceled or
+// drained
+func (e *Execution) clean(p *phase) bool {
+	if len(p.calls) != len(e.snap.nodes[p.node].funcs) {
+		return false
+	}
+	for i := range p.states {
+		if atomic.LoadInt32(&p.states[i]) != callDone {
+			return false
+		}
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	ns := e.stats.Nodes[p.node]
+	return len(ns.Errors) == 0 && ns.Skipped == 0
+}
+
+// reports whether the run goes on to the nodes following a completed phase
+func (e *Execution) carriesOn() bool {
+	return atomic.LoadInt32(&e.aborted) == 0 &&
+		!(e.snap.opts.stopOnError && e.failed()) &&
+		(e.ctx == nil || e.ctx.Err() == nil)
+}
+
+// waits for every dependency to be satisfied, or for the run's context to
+// be done or the phase to be drained, in which case the funcs will be
+// skipped anyway
+func (e *Execution) await(p *phase, deps []dependency) {
+	var done <-chan struct{}
+	if e.ctx != nil {
+		done = e.ctx.Done()
+	}
+	for _, d := range deps {
+		select {
+		case <-d.latches.get(d.name):
+		case <-done:
+			return
+		case <-p.finished:
+			return
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package chain_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestWaitFor(t *testing.T) {
+	db := chain.New()
+	ready, err := db.Register(func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ready.SetName("ready"); err != nil {
+		t.Fatal(err)
+	}
+
+	var served int32
+	http := chain.New()
+	serve, err := http.Register(func() { atomic.AddInt32(&served, 1) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = serve.WaitFor(db, "ready"); err != nil {
+		t.Fatal(err)
+	}
+
+	e := http.Start()
+	time.Sleep(10 * time.Millisecond)
+	if atomic.LoadInt32(&served) != 0 {
+		t.Fatal("node ran before the node it waits for completed")
+	}
+	if err = db.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err = e.Err(); err != nil || atomic.LoadInt32(&served) != 1 {
+		t.Fatalf("node did not run once its dependency completed (%v)", err)
+	}
+
+	// the dependency stays satisfied
+	if err = http.Run(); err != nil || atomic.LoadInt32(&served) != 2 {
+		t.Fatalf("second run failed (%v)", err)
+	}
+
+	other := chain.New()
+	blocked, err := other.Register(func() { t.Error("should never run") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = blocked.WaitFor(db, "never"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err = other.RunContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+// reports whether a node waiting for the named node in c gets to run
+func satisfied(t *testing.T, c chain.Root, name string) bool {
+	w := chain.New()
+	p, err := w.Register(func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.WaitFor(c, name); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	return w.RunContext(ctx) == nil
+}
+
+func TestWaitForStopChain(t *testing.T) {
+	failure := errors.New("failure")
+	c := chain.New(chain.WithRecovery(chain.StopChain), chain.WithWorkers(1))
+	first, err := c.Register(func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failing, err := first.After(func() error { return failure })
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopped, err := failing.After(func() { t.Error("should never run") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p, name := range map[chain.Predicate]string{first: "first", failing: "failing", stopped: "stopped"} {
+		if err = p.SetName(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = c.Run(); !errors.Is(err, failure) {
+		t.Fatalf("expected %v, got %v", failure, err)
+	}
+	if !satisfied(t, c, "first") {
+		t.Error("node that ran was not marked completed")
+	}
+	if satisfied(t, c, "failing") {
+		t.Error("node that failed was marked completed")
+	}
+	if satisfied(t, c, "stopped") {
+		t.Error("node skipped by StopChain was marked completed")
+	}
+}
+
+func TestWaitForDrain(t *testing.T) {
+	hang := make(chan struct{})
+	defer close(hang)
+	c := chain.New(chain.WithDrain(20 * time.Millisecond))
+	p, err := c.Register(func() { <-hang })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.SetName("drained"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Run(); !errors.Is(err, chain.ErrDrained) {
+		t.Fatalf("expected %v, got %v", chain.ErrDrained, err)
+	}
+	if satisfied(t, c, "drained") {
+		t.Error("drained node was marked completed")
+	}
+}
+
+func TestWaitForEmptyNode(t *testing.T) {
+	l := chain.NewLifecycle(chain.WithStopOnError())
+	if _, err := l.StartPhase().Register(func() {}); err != nil {
+		t.Fatal(err)
+	}
+	if satisfied(t, l.Root, chain.PhaseInit) || satisfied(t, l.Root, chain.PhaseReady) {
+		t.Fatal("empty nodes marked completed before the chain ran")
+	}
+	if err := l.Run(); err != nil {
+		t.Fatal(err)
+	}
+	// empty phases before and after the only node with funcs
+	for _, name := range []string{chain.PhaseInit, chain.PhaseReady, chain.PhaseStop} {
+		if !satisfied(t, l.Root, name) {
+			t.Errorf("empty node %q was not marked completed", name)
+		}
+	}
+
+	// a run that stops short doesn't get past the nodes that follow
+	failing := chain.NewLifecycle(chain.WithStopOnError())
+	if _, err := failing.StartPhase().Register(func() error { return errors.New("failure") }); err != nil {
+		t.Fatal(err)
+	}
+	failing.Run()
+	if !satisfied(t, failing.Root, chain.PhaseInit) || satisfied(t, failing.Root, chain.PhaseReady) {
+		t.Error("empty nodes of a failed run marked incorrectly")
+	}
+}
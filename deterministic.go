@@ -0,0 +1,57 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"math/rand"
+)
+
+// WithDeterministic makes every run of a chain reproducible, for tracking
+// down ordering dependent test failures. Runs use a single worker, so no
+// two funcs ever run at the same time, and the funcs of each node are
+// called in an order which is a shuffle of their registration order
+// determined by seed. Every run with the same seed calls funcs in the
+// same order; trying a range of seeds exercises different orders. Funcs
+// which must rendezvous with others in the same node deadlock in this
+// mode.
+func WithDeterministic(seed int64) Option {
+	return func(o *options) {
+		o.deterministic = true
+		o.seed = seed
+	}
+}
+
+// returns the source of a run's dispatch order, or nil if the chain isn't
+// deterministic
+func (o *options) shuffler() *rand.Rand {
+	if !o.deterministic {
+		return nil
+	}
+	return rand.New(rand.NewSource(o.seed))
+}
+
+func shuffle(rng *rand.Rand, p *phase) {
+	rng.Shuffle(len(p.calls), p.swap)
+}
+
+// swaps two of a phase's calls along with everything kept about them
+func (p *phase) swap(i, j int) {
+	p.calls[i], p.calls[j] = p.calls[j], p.calls[i]
+	if p.skipped != nil {
+		p.skipped[i], p.skipped[j] = p.skipped[j], p.skipped[i]
+	}
+	if p.sets != nil {
+		p.sets[i], p.sets[j] = p.sets[j], p.sets[i]
+	}
+}
@@ -0,0 +1,33 @@
+package chain_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestDeterministic(t *testing.T) {
+	order := func(seed int64) []int {
+		var got []int
+		c := chain.New(chain.WithDeterministic(seed))
+		for i := 0; i < 8; i++ {
+			i := i
+			c.Register(func() { got = append(got, i) })
+		}
+		c.Run()
+		return got
+	}
+	first := order(1)
+	if len(first) != 8 || !reflect.DeepEqual(first, order(1)) {
+		t.Fatalf("same seed gave different orders")
+	}
+	orders := make(map[string]bool)
+	for seed := int64(0); seed < 10; seed++ {
+		orders[fmt.Sprint(order(seed))] = true
+	}
+	if len(orders) < 2 {
+		t.Fatal("seed has no effect on order")
+	}
+}
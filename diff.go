@@ -0,0 +1,246 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+)
+
+// ChangeKind identifies the type of structural difference described by a
+// Change.
+type ChangeKind int
+
+const (
+	// A node exists only in the second chain.
+	NodeAdded ChangeKind = iota
+	// A node exists only in the first chain.
+	NodeRemoved
+	// A node exists in both chains but its position relative to the other
+	// nodes has changed.
+	NodeMoved
+	// A node exists in both chains but holds a different number of funcs.
+	NodeFuncsChanged
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case NodeAdded:
+		return "added"
+	case NodeRemoved:
+		return "removed"
+	case NodeMoved:
+		return "moved"
+	case NodeFuncsChanged:
+		return "funcs changed"
+	}
+	return "ChangeKind(?)"
+}
+
+// Change is a single structural difference between two chains. A and B
+// are the node positions in the first and second chain respectively, or
+// -1 if the node doesn't exist in that chain. FuncsA and FuncsB are the
+// number of funcs registered with the node in each chain.
+type Change struct {
+	Kind   ChangeKind
+	A, B   int
+	FuncsA int
+	FuncsB int
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case NodeAdded:
+		return fmt.Sprintf("node %d added (%d funcs)", c.B, c.FuncsB)
+	case NodeRemoved:
+		return fmt.Sprintf("node %d removed (%d funcs)", c.A, c.FuncsA)
+	case NodeMoved:
+		return fmt.Sprintf("node %d moved to %d", c.A, c.B)
+	}
+	return fmt.Sprintf("node %d (now %d) funcs changed from %d to %d", c.A, c.B, c.FuncsA, c.FuncsB)
+}
+
+type diffNode struct {
+	pos   int
+	funcs []interface{}
+}
+
+// identifies a named func by its symbol and registration site, which stay
+// the same across processes running the same code.
+type funcRef struct {
+	name, site string
+}
+
+// closures compiled from func literals are named after their enclosing
+// func with a .funcN suffix
+var anonymous = regexp.MustCompile(`\.func\d+(\.\d+)*$`)
+
+// returns a key usable to match up the func behind an entry across
+// chains, or nil if there isn't one. Named funcs and methods are matched
+// by symbol name and site, anonymous closures only by identity.
+func funcKey(e *entry) interface{} {
+	cp := e.fn
+	if p, ok := cp.(prefixProxy); ok {
+		cp = p.fn
+	}
+	if val, ok := cp.(reflect.Value); ok {
+		if val.Kind() == reflect.Func {
+			if f := runtime.FuncForPC(val.Pointer()); f != nil && !anonymous.MatchString(f.Name()) {
+				return funcRef{f.Name(), e.site}
+			}
+		}
+		return funcIdentity(val.Interface())
+	}
+	if reflect.TypeOf(cp).Comparable() {
+		return cp
+	}
+	return nil
+}
+
+func diffLayout(cn *chainNode) (nodes []diffNode) {
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+
+	pos := 0
+	for n := cn.getFirst(); n != nil; n, pos = n.getNext(), pos+1 {
+		dn := diffNode{pos: pos}
+		for _, e := range n.funcs {
+			dn.funcs = append(dn.funcs, funcKey(e))
+		}
+		if len(dn.funcs) > 0 {
+			nodes = append(nodes, dn)
+		}
+	}
+	return
+}
+
+// Diff compares the structure of two chains and returns the differences
+// between them, ordered by position in the first chain (added nodes come
+// last). Nodes are matched up by the funcs registered with them: named
+// funcs and methods by symbol name and registration site, so chains built
+// by the same code in different processes line up, and anonymous closures
+// by identity, so those only match within a chain and its Clone()s. Nodes
+// without any funcs never run and are ignored.
+func Diff(a, b Root) (changes []Change) {
+	an, aok := asNode(a)
+	bn, bok := asNode(b)
+	if !aok || !bok {
+		panic("chain.Diff requires two chain roots")
+	}
+	A, B := diffLayout(an), diffLayout(bn)
+
+	// the same func may be registered with several nodes, so each key
+	// maps to every node in b holding it, in order
+	where := make(map[interface{}][]int)
+	for i, n := range B {
+		for _, k := range n.funcs {
+			if k != nil {
+				if w := where[k]; len(w) == 0 || w[len(w)-1] != i {
+					where[k] = append(w, i)
+				}
+			}
+		}
+	}
+
+	// each node in a is matched with the node in b holding most of its
+	// funcs, the first unclaimed one for funcs held by several
+	type match struct{ a, b int }
+	var matches []match
+	claimed := make(map[int]bool)
+	for i, n := range A {
+		votes := make(map[int]int)
+		best, most := -1, 0
+		for _, k := range n.funcs {
+			if k == nil {
+				continue
+			}
+			for _, j := range where[k] {
+				if claimed[j] {
+					continue
+				}
+				votes[j]++
+				if votes[j] > most || (votes[j] == most && j < best) {
+					best, most = j, votes[j]
+				}
+				break
+			}
+		}
+		if best < 0 {
+			changes = append(changes, Change{Kind: NodeRemoved, A: n.pos, B: -1, FuncsA: len(n.funcs)})
+			continue
+		}
+		claimed[best] = true
+		matches = append(matches, match{i, best})
+	}
+
+	// matched nodes not part of the longest run that kept their relative
+	// order have moved.
+	stay := make(map[int]bool)
+	for _, i := range longestIncreasing(len(matches), func(i int) int { return matches[i].b }) {
+		stay[i] = true
+	}
+	for i, m := range matches {
+		na, nb := A[m.a], B[m.b]
+		if !stay[i] {
+			changes = append(changes, Change{Kind: NodeMoved, A: na.pos, B: nb.pos,
+				FuncsA: len(na.funcs), FuncsB: len(nb.funcs)})
+		}
+		if len(na.funcs) != len(nb.funcs) {
+			changes = append(changes, Change{Kind: NodeFuncsChanged, A: na.pos, B: nb.pos,
+				FuncsA: len(na.funcs), FuncsB: len(nb.funcs)})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].A < changes[j].A
+	})
+
+	for j, n := range B {
+		if !claimed[j] {
+			changes = append(changes, Change{Kind: NodeAdded, A: -1, B: n.pos, FuncsB: len(n.funcs)})
+		}
+	}
+	return
+}
+
+// returns the indices of a longest strictly increasing subsequence of the
+// n values returned by val.
+func longestIncreasing(n int, val func(int) int) []int {
+	tails := make([]int, 0, n)
+	prev := make([]int, n)
+	for i := 0; i < n; i++ {
+		j := sort.Search(len(tails), func(k int) bool {
+			return val(tails[k]) >= val(i)
+		})
+		if j > 0 {
+			prev[i] = tails[j-1]
+		} else {
+			prev[i] = -1
+		}
+		if j == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[j] = i
+		}
+	}
+	seq := make([]int, len(tails))
+	if len(tails) > 0 {
+		for i, k := len(tails)-1, tails[len(tails)-1]; i >= 0; i, k = i-1, prev[k] {
+			seq[i] = k
+		}
+	}
+	return seq
+}
@@ -0,0 +1,92 @@
+package chain_test
+
+import (
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestDiff(t *testing.T) {
+	f1, f2, f3, f4, f5 := func() {}, func() {}, func() {}, func() {}, func() {}
+
+	a := chain.New()
+	p, _ := a.Register(f1)
+	p, _ = p.After(f2)
+	p.After(f3)
+
+	same := chain.New()
+	p, _ = same.Register(f1)
+	p, _ = p.After(f2)
+	p.After(f3)
+	if changes := chain.Diff(a, same); len(changes) != 0 {
+		t.Fatalf("identical chains differ: %v", changes)
+	}
+
+	// f3's node now runs before f2's, f2's node gains f4 and f5 is new
+	b := chain.New()
+	p, _ = b.Register(f1)
+	p, _ = p.After(f3)
+	p, _ = p.After(f2)
+	p.Register(f4)
+	p.After(f5)
+
+	kinds := make(map[chain.ChangeKind]int)
+	for _, c := range chain.Diff(a, b) {
+		t.Log(c)
+		kinds[c.Kind]++
+	}
+	if len(kinds) != 3 || kinds[chain.NodeAdded] != 1 || kinds[chain.NodeFuncsChanged] != 1 || kinds[chain.NodeMoved] != 1 {
+		t.Fatalf("unexpected changes: %v", kinds)
+	}
+}
+
+type diffStage struct{ n int }
+
+func (s *diffStage) run() { s.n++ }
+
+func diffStart() {}
+
+// builds the same chain from fresh receivers, as another process would
+func diffBuild() chain.Root {
+	c := chain.New()
+	p, _ := c.Register(diffStart)
+	p.After((&diffStage{}).run)
+	return c
+}
+
+func TestDiffNamedFuncs(t *testing.T) {
+	if changes := chain.Diff(diffBuild(), diffBuild()); len(changes) != 0 {
+		t.Fatalf("chains built by the same code differ: %v", changes)
+	}
+
+	// the same funcs registered from elsewhere are different funcs
+	c := chain.New()
+	p, _ := c.Register(diffStart)
+	p.After((&diffStage{}).run)
+	kinds := make(map[chain.ChangeKind]int)
+	for _, c := range chain.Diff(diffBuild(), c) {
+		kinds[c.Kind]++
+	}
+	if len(kinds) != 2 || kinds[chain.NodeRemoved] != 2 || kinds[chain.NodeAdded] != 2 {
+		t.Fatalf("unexpected changes: %v", kinds)
+	}
+}
+
+func diffRepeated() chain.Root {
+	c := chain.New()
+	p := c.Head()
+	for i := 0; i < 3; i++ {
+		p, _ = p.After(diffStart)
+	}
+	return c
+}
+
+func TestDiffRepeated(t *testing.T) {
+	c := diffRepeated()
+	if changes := chain.Diff(c, c); len(changes) != 0 {
+		t.Fatalf("chain differs from itself: %v", changes)
+	}
+	if changes := chain.Diff(c, diffRepeated()); len(changes) != 0 {
+		t.Fatalf("chains built by the same code differ: %v", changes)
+	}
+}
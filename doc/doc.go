@@ -0,0 +1,117 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+// Package doc generates Markdown documentation of a call chain's layout:
+// each phase (node) in execution order, the funcs registered with it and
+// what it waits for. As a chain only exists in the process that builds
+// it, the usual approach is a test which builds the chain and compares
+// the output of Write with a file checked in alongside the runbooks, or
+// rewrites that file when run with a flag.
+package doc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jsipprell/go-chain"
+)
+
+// Options controls the documentation written by Write.
+type Options struct {
+	// Title heads the document, "Call chain" if empty.
+	Title string
+	// NoSites omits where funcs were registered from. Registration sites
+	// change whenever code moves, making checked in documentation churn.
+	NoSites bool
+}
+
+// Write writes Markdown documentation of the current layout of a chain to
+// w (see chain.TopologyOf).
+func Write(w io.Writer, r chain.Root, o Options) error {
+	bw := bufio.NewWriter(w)
+	title := o.Title
+	if title == "" {
+		title = "Call chain"
+	}
+	t := chain.TopologyOf(r)
+	fmt.Fprintf(bw, "# %s\n\n", title)
+	fmt.Fprintf(bw, "%d phase(s), run in order. Funcs in the same phase run concurrently.\n", len(t.Nodes))
+	for i := range t.Nodes {
+		writeNode(bw, t, &t.Nodes[i], o)
+	}
+	return bw.Flush()
+}
+
+func writeNode(w *bufio.Writer, t *chain.Topology, n *chain.TopologyNode, o Options) {
+	fmt.Fprintf(w, "\n## %s\n\n", phaseName(n))
+	if n.After < 0 {
+		fmt.Fprintf(w, "Runs first.\n")
+	} else {
+		fmt.Fprintf(w, "Runs after %s.\n", phaseName(&t.Nodes[n.After]))
+	}
+	if len(n.WaitFor) > 0 {
+		names := make([]string, len(n.WaitFor))
+		for i, name := range n.WaitFor {
+			names[i] = "`" + name + "`"
+		}
+		fmt.Fprintf(w, "Waits for %s in other chains.\n", strings.Join(names, ", "))
+	}
+	if len(n.Funcs) == 0 {
+		fmt.Fprintf(w, "\n_No funcs registered._\n")
+		return
+	}
+	if o.NoSites {
+		fmt.Fprintf(w, "\n| Func | Package | Type |\n| --- | --- | --- |\n")
+	} else {
+		fmt.Fprintf(w, "\n| Func | Package | Type | Registered at |\n| --- | --- | --- | --- |\n")
+	}
+	for _, f := range n.Funcs {
+		name := code(f.Symbol)
+		if f.Name != "" {
+			name = fmt.Sprintf("%s (%s)", code(f.Name), code(f.Symbol))
+		}
+		fmt.Fprintf(w, "| %s | %s | %s |", name, code(symbolPackage(f.Symbol)), code(f.Type))
+		if !o.NoSites {
+			fmt.Fprintf(w, " %s |", code(f.Site))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func phaseName(n *chain.TopologyNode) string {
+	if n.Name != "" {
+		return fmt.Sprintf("Phase %d: %s", n.ID+1, n.Name)
+	}
+	return fmt.Sprintf("Phase %d", n.ID+1)
+}
+
+// returns the import path of the package a func symbol belongs to, such
+// as "example.com/db" for "example.com/db.(*Pool).Open"
+func symbolPackage(sym string) string {
+	sym = strings.TrimLeft(sym, "*")
+	slash := strings.LastIndex(sym, "/") + 1
+	if dot := strings.Index(sym[slash:], "."); dot >= 0 {
+		return sym[:slash+dot]
+	}
+	return ""
+}
+
+// formats a table cell as code, pipes would end the cell
+func code(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + strings.ReplaceAll(s, "|", `\|`) + "`"
+}
@@ -0,0 +1,56 @@
+package doc_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+	"github.com/jsipprell/go-chain/doc"
+)
+
+func connect() error { return nil }
+func migrate() error { return nil }
+
+func TestWrite(t *testing.T) {
+	c := chain.New()
+	p, err := c.Register(connect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.SetName("db/connect"); err != nil {
+		t.Fatal(err)
+	}
+	if p, err = p.After(migrate); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.WaitFor(chain.New(), "config"); err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err = doc.Write(&b, c, doc.Options{Title: "Startup", NoSites: true}); err != nil {
+		t.Fatal(err)
+	}
+	want := "# Startup\n\n" +
+		"2 phase(s), run in order. Funcs in the same phase run concurrently.\n\n" +
+		"## Phase 1: db/connect\n\n" +
+		"Runs first.\n\n" +
+		"| Func | Package | Type |\n| --- | --- | --- |\n" +
+		"| `github.com/jsipprell/go-chain/doc_test.connect` | `github.com/jsipprell/go-chain/doc_test` | `func() error` |\n\n" +
+		"## Phase 2\n\n" +
+		"Runs after Phase 1: db/connect.\n" +
+		"Waits for `config` in other chains.\n\n" +
+		"| Func | Package | Type |\n| --- | --- | --- |\n" +
+		"| `github.com/jsipprell/go-chain/doc_test.migrate` | `github.com/jsipprell/go-chain/doc_test` | `func() error` |\n"
+	if got := b.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	b.Reset()
+	if err = doc.Write(&b, c, doc.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "| Registered at |") || !strings.Contains(b.String(), "doc/doc_test.go:") {
+		t.Errorf("sites missing from:\n%s", b.String())
+	}
+}
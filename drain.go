@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ErrDrained is wrapped by the error recorded for each func abandoned by
+// a drain run (see WithDrain).
+var ErrDrained = errors.New("func exceeded its drain budget")
+
+// WithDrain gives every run of the chain an overall deadline of d from the
+// moment it starts, for shutdown chains which must finish even when a func
+// hangs. As each node is released it is given a share of the time left
+// proportional to its share of the funcs left to run. Once a node's budget
+// runs out its funcs which haven't started yet are skipped, those still
+// running are abandoned and the next node is released regardless. Each
+// abandoned func is recorded as failing with an error wrapping ErrDrained
+// (see also Execution.Abandoned()) and a worker is started to take the
+// place of the one it holds. The run finishes without waiting for them,
+// and anything they do afterwards is not reflected in its errors or
+// statistics.
+func WithDrain(d time.Duration) Option {
+	return func(o *options) {
+		o.drain = d
+	}
+}
+
+// Abandoned waits for the run to finish and returns the funcs abandoned
+// because they exceeded their node's drain budget (see WithDrain).
+func (e *Execution) Abandoned() []FuncInfo {
+	e.Wait()
+	return e.drained
+}
+
+// the states of a call in a drain run
+const (
+	callPending int32 = iota
+	callRunning
+	callDone
+	callAbandoned
+	callDrained
+)
+
+// claims a call for the worker about to run it, false if its node has been
+// drained in the meantime
+func (c call) begin() bool {
+	return c.states == nil || atomic.CompareAndSwapInt32(&c.states[c.index], callPending, callRunning)
+}
+
+// reports whether the call is still accounted for by the worker that ran it
+// rather than having been abandoned
+func (c call) end() bool {
+	return c.states == nil || atomic.CompareAndSwapInt32(&c.states[c.index], callRunning, callDone)
+}
+
+// reports whether what a call does should still be recorded, must be called
+// with the run locked
+func (e *Execution) live(c call) bool {
+	if e.abandoned {
+		return false
+	}
+	return c.states == nil || c.entry == nil || atomic.LoadInt32(&c.states[c.index]) != callAbandoned
+}
+
+// starts the clock on a node released by a drain run
+func (e *Execution) budget(p *phase) {
+	e.queueing.Add(1)
+	funcs := 0
+	for _, q := range e.plan[p.index:] {
+		funcs += len(q.calls)
+	}
+	var d time.Duration
+	if left := time.Until(e.deadline); left > 0 {
+		d = time.Duration(float64(left) * float64(len(p.calls)) / float64(funcs))
+	}
+	e.lock.Lock()
+	p.budget = d
+	p.timer = time.AfterFunc(d, func() { e.drain(p) })
+	e.lock.Unlock()
+}
+
+// forcibly completes a node whose budget has run out
+func (e *Execution) drain(p *phase) {
+	var n int32
+	e.lock.Lock()
+	for i, ent := range p.calls {
+		c := call{phase: p, entry: ent, index: i}
+		switch {
+		case atomic.CompareAndSwapInt32(&p.states[i], callPending, callDrained):
+			if !e.abandoned {
+				e.stats.Nodes[p.node].Skipped++
+			}
+			e.snap.opts.trace.add(e.info, TraceFuncSkipped, c, time.Time{}, nil)
+		case atomic.CompareAndSwapInt32(&p.states[i], callRunning, callAbandoned):
+			fi := ent.info(p.node)
+			err := fmt.Errorf("%s: %w after %v", fi.Symbol, ErrDrained, p.budget)
+			if !e.abandoned {
+				e.drained = append(e.drained, fi)
+				e.stats.Nodes[p.node].Errors = append(e.stats.Nodes[p.node].Errors, err)
+				e.errors = append(e.errors, err)
+			}
+			e.hung++
+			e.workers.Add(1)
+			go e.worker()
+		default:
+			continue
+		}
+		n++
+	}
+	e.lock.Unlock()
+	if n > 0 && atomic.AddInt32(&p.remaining, -n) == 0 {
+		e.completed(p)
+	}
+}
+
+// stops the clock on a completed node
+func (e *Execution) budgeted(p *phase) {
+	e.lock.Lock()
+	t := p.timer
+	e.lock.Unlock()
+	if t != nil {
+		t.Stop()
+	}
+}
+
+// lets the workers go once the last node has completed, after anything
+// still queueing calls of drained nodes has given up
+func (e *Execution) closeWork() {
+	if e.planDone != nil {
+		e.queueing.Wait()
+	}
+	close(e.work)
+	if e.planDone != nil {
+		close(e.planDone)
+	}
+}
+
+// waits for every worker to exit, except those held by abandoned funcs,
+// once the last node of a drain run has completed
+func (e *Execution) awaitDrained(idle <-chan struct{}) {
+	select {
+	case <-idle:
+		return
+	case <-e.planDone:
+	}
+	e.lock.Lock()
+	hung := e.hung
+	e.lock.Unlock()
+	if hung == 0 {
+		<-idle
+	}
+}
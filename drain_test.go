@@ -0,0 +1,45 @@
+package chain_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestDrain(t *testing.T) {
+	hang := make(chan struct{})
+	defer close(hang)
+	var ran []string
+	c := chain.New(chain.WithWorkers(1), chain.WithDrain(100*time.Millisecond))
+	p, err := c.Register(func() { <-hang }, func() { ran = append(ran, "queued") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.After(func() { ran = append(ran, "next") }); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	e := c.Start()
+	err = e.Err()
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("drain run took %v", d)
+	}
+	if !errors.Is(err, chain.ErrDrained) {
+		t.Errorf("run returned %v", err)
+	}
+	if len(ran) != 1 || ran[0] != "next" {
+		t.Errorf("ran %v", ran)
+	}
+	if fns := e.Abandoned(); len(fns) != 1 || fns[0].Node != 0 {
+		t.Errorf("abandoned %v", fns)
+	}
+	if s := e.Stats().Nodes[0]; s.Skipped != 1 || len(s.Errors) != 1 {
+		t.Errorf("first node skipped %d funcs with errors %v", s.Skipped, s.Errors)
+	}
+	if e.State() != chain.Failed {
+		t.Errorf("run state is %v", e.State())
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"reflect"
+)
+
+// EventKind identifies the type of modification an Event describes.
+type EventKind int
+
+const (
+	// A func was registered with Node.
+	EventFuncRegistered EventKind = iota
+	// A func was removed from Node.
+	EventFuncRemoved
+	// Node was inserted into the chain.
+	EventNodeInserted
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventFuncRegistered:
+		return "FuncRegistered"
+	case EventFuncRemoved:
+		return "FuncRemoved"
+	case EventNodeInserted:
+		return "NodeInserted"
+	}
+	return "EventKind(?)"
+}
+
+// Event describes a single modification to a call chain. Func is the type
+// of the func registered or removed and is nil for EventNodeInserted.
+type Event struct {
+	Kind EventKind
+	Node Predicate
+	Func reflect.Type
+}
+
+func (cn *chainNode) OnChange(fn func(Event)) {
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	cn.opts.listeners = append(cn.opts.listeners, fn)
+}
+
+// queues an event for delivery by notify(), must be called with the chain
+// locked. As every modification of the chain's layout queues an event this
+// is also where the cached snapshot is discarded.
+func (cn *chainNode) queue(ev Event) {
+	cn.opts.cache.invalidate()
+	if len(cn.opts.listeners) > 0 {
+		cn.opts.pending = append(cn.opts.pending, ev)
+	}
+}
+
+// delivers all queued events, must be called with the chain unlocked.
+func (cn *chainNode) notify() {
+	cn.lock.Lock()
+	pending := cn.opts.pending
+	listeners := cn.opts.listeners
+	cn.opts.pending = nil
+	cn.lock.Unlock()
+
+	for _, ev := range pending {
+		for _, fn := range listeners {
+			fn(ev)
+		}
+	}
+}
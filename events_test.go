@@ -0,0 +1,47 @@
+package chain_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestOnChange(t *testing.T) {
+	var events []chain.Event
+	c := chain.NewTyped(TestFunc(nil))
+	c.OnChange(func(ev chain.Event) {
+		// listeners must be able to use the chain
+		_ = c.Len()
+		events = append(events, ev)
+	})
+
+	pred, err := c.Register(func(*testing.T) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := pred.After(func(*testing.T) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []chain.EventKind{
+		chain.EventFuncRegistered,
+		chain.EventNodeInserted,
+		chain.EventFuncRegistered,
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %d: %v", len(want), len(events), events)
+	}
+	for i, k := range want {
+		if events[i].Kind != k {
+			t.Fatalf("event %d is %v, expected %v", i, events[i].Kind, k)
+		}
+	}
+	if events[1].Node != after || events[2].Node != after {
+		t.Fatal("events do not identify the inserted node")
+	}
+	if events[2].Func != reflect.TypeOf(TestFunc(nil)) {
+		t.Fatalf("expected func type %v, got %v", reflect.TypeOf(TestFunc(nil)), events[2].Func)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"fmt"
+)
+
+// ArgSets are the separate sets of arguments given to the funcs of a
+// fan-out run (see RunFanOut). Each set is prepared exactly as the
+// arguments of an ordinary run would be, so adapters, bound arguments and
+// placeholders all apply.
+type ArgSets struct {
+	byFunc bool
+	list   [][]interface{}
+	named  map[string][]interface{}
+}
+
+// PerNode gives every func of the ith node of the chain the ith set.
+func PerNode(sets ...[]interface{}) ArgSets {
+	return ArgSets{list: sets}
+}
+
+// PerFunc gives the ith func of every node the ith set, for data-parallel
+// chains which have a func per shard in each node.
+func PerFunc(sets ...[]interface{}) ArgSets {
+	return ArgSets{byFunc: true, list: sets}
+}
+
+// PerName gives funcs the set named after them (see RegisterMap) or, if
+// there is none, the set named after their node.
+func PerName(sets map[string][]interface{}) ArgSets {
+	return ArgSets{named: sets}
+}
+
+func (cn *chainNode) RunFanOut(sets ArgSets) error {
+	return cn.Snapshot().RunFanOut(sets)
+}
+
+func (cn *chainNode) StartFanOut(sets ArgSets) *Execution {
+	return cn.Snapshot().StartFanOut(sets)
+}
+
+// RunFanOut runs the snapshot exactly as Root.RunFanOut() would.
+func (s *Snapshot) RunFanOut(sets ArgSets) error {
+	return s.opts.handle(s.StartFanOut(sets).Err())
+}
+
+// StartFanOut is the asynchronous form of RunFanOut.
+func (s *Snapshot) StartFanOut(sets ArgSets) *Execution {
+	return s.start(&Execution{fan: &sets}, nil, nil)
+}
+
+// returns the prepared set of arguments for the ith func of a node in a
+// fan-out run, each set is only prepared once
+func (e *Execution) fanned(node, i int, ent *entry) (*argSet, error) {
+	var key interface{}
+	var args []interface{}
+	var ok bool
+	switch f := e.fan; {
+	case f.named != nil:
+		if args, ok = f.named[ent.name]; ok && ent.name != "" {
+			key = ent.name
+		} else if name := e.snap.nodes[node].name; name != "" {
+			args, ok = f.named[name]
+			key = name
+		}
+		if !ok {
+			return nil, fmt.Errorf("no argument set named after %s or its node", funcName(ent.fn))
+		}
+	case f.byFunc:
+		if i >= len(f.list) {
+			return nil, fmt.Errorf("node %d has %d funcs but there are only %d argument sets", node, i+1, len(f.list))
+		}
+		key, args = i, f.list[i]
+	default:
+		if node >= len(f.list) {
+			return nil, fmt.Errorf("chain has %d nodes but there are only %d argument sets", node+1, len(f.list))
+		}
+		key, args = node, f.list[node]
+	}
+	if a, ok := e.fanSets[key]; ok {
+		return a, nil
+	}
+	a, err := e.prepare(args)
+	if err != nil {
+		return nil, err
+	}
+	if e.fanSets == nil {
+		e.fanSets = make(map[interface{}]*argSet)
+	}
+	e.fanSets[key] = a
+	return a, nil
+}
@@ -0,0 +1,83 @@
+package chain_test
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestFanOut(t *testing.T) {
+	var lock sync.Mutex
+	var got []string
+	shard := func(name string) func(string) {
+		return func(arg string) {
+			lock.Lock()
+			defer lock.Unlock()
+			got = append(got, name+":"+arg)
+		}
+	}
+	c := chain.New(chain.WithWorkers(1))
+	p, err := c.Register(shard("init0"), shard("init1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.After(shard("load0"), shard("load1")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = c.RunFanOut(chain.PerFunc([]interface{}{"a"}, []interface{}{"b"})); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got[:2])
+	sort.Strings(got[2:])
+	if want := []string{"init0:a", "init1:b", "load0:a", "load1:b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("per func got %v, want %v", got, want)
+	}
+
+	got = nil
+	if err = c.RunFanOut(chain.PerNode([]interface{}{"x"}, []interface{}{"y"})); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got[:2])
+	sort.Strings(got[2:])
+	if want := []string{"init0:x", "init1:x", "load0:y", "load1:y"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("per node got %v, want %v", got, want)
+	}
+
+	got = nil
+	if err = c.RunFanOut(chain.PerNode([]interface{}{"x"})); err == nil {
+		t.Error("missing argument set was accepted")
+	}
+	if len(got) != 0 {
+		t.Errorf("funcs were called despite a missing set: %v", got)
+	}
+}
+
+func TestFanOutPerName(t *testing.T) {
+	got := make(map[string]interface{})
+	c := chain.New(chain.WithWorkers(1))
+	p, err := c.Head().RegisterMap(map[string]interface{}{
+		"special": func(v int) { got["special"] = v },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.Register(func(v int) { got["other"] = v }); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.SetName("node"); err != nil {
+		t.Fatal(err)
+	}
+	if err = c.RunFanOut(chain.PerName(map[string][]interface{}{
+		"special": {1},
+		"node":    {2},
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if got["special"] != 1 || got["other"] != 2 {
+		t.Errorf("funcs were passed %v", got)
+	}
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"log"
+	"reflect"
+)
+
+var filterArgsType = reflect.TypeOf([]interface{}(nil))
+
+// TypedFilter converts a filter func of the form
+//
+//	func(T, []interface{}) bool
+//
+// into one suitable for passing to RunFiltered(), where T is any type
+// (typically the chain's func type or a CallProxy implementation). Funcs
+// that would fail a type assertion to T are never passed to fn and are
+// filtered out.
+// TypedFilter panics if fn is not of the correct form.
+//
+// Example:
+//
+//	c.RunFiltered(chain.TypedFilter(func(w *Wrapper, args []interface{}) bool {
+//		return w.Score > 0
+//	}))
+func TypedFilter(fn interface{}) func(interface{}, []interface{}) bool {
+	val := reflect.ValueOf(fn)
+	T := val.Type()
+	if T.Kind() != reflect.Func || T.NumIn() != 2 || T.NumOut() != 1 ||
+		T.In(1) != filterArgsType || T.Out(0).Kind() != reflect.Bool {
+		log.Panicf("type <%v> is not a valid filter", T)
+	}
+	want := T.In(0)
+
+	return func(i interface{}, args []interface{}) bool {
+		v := reflect.ValueOf(i)
+		if !v.IsValid() {
+			return false
+		}
+		// same semantics as a type assertion
+		if want.Kind() == reflect.Interface {
+			if !v.Type().Implements(want) {
+				return false
+			}
+			v = v.Convert(want)
+		} else if v.Type() != want {
+			return false
+		}
+		return val.Call([]reflect.Value{v, reflect.ValueOf(args)})[0].Bool()
+	}
+}
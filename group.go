@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Group runs several independent chains together, each chain starting as
+// soon as every chain it has been ordered after has finished. Chains with
+// no ordering between them run concurrently.
+type Group struct {
+	lock   sync.Mutex
+	names  []string
+	chains map[string]Root
+	after  map[string][]string
+}
+
+// Returns a new, empty group of chains
+func NewGroup() *Group {
+	return &Group{
+		chains: make(map[string]Root),
+		after:  make(map[string][]string),
+	}
+}
+
+// Add adds a chain to the group under name.
+func (g *Group) Add(name string, r Root) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if _, ok := g.chains[name]; ok {
+		return fmt.Errorf("%q: %w", name, ErrNameInUse)
+	}
+	g.names = append(g.names, name)
+	g.chains[name] = r
+	return nil
+}
+
+// Chain returns the chain added to the group under name.
+func (g *Group) Chain(name string) (Root, bool) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	r, ok := g.chains[name]
+	return r, ok
+}
+
+// Order declares that the chain named first must finish before the chain
+// named then is started.
+func (g *Group) Order(first, then string) error {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	for _, name := range []string{first, then} {
+		if _, ok := g.chains[name]; !ok {
+			return fmt.Errorf("%q: %w", name, ErrUnknownChain)
+		}
+	}
+	if first == then || g.runsAfter(first, then) {
+		return fmt.Errorf("%q before %q: %w", first, then, ErrCycle)
+	}
+	g.after[then] = append(g.after[then], first)
+	return nil
+}
+
+// reports whether the chain named a is already ordered after b, must be
+// called with the group locked.
+func (g *Group) runsAfter(a, b string) bool {
+	for _, prev := range g.after[a] {
+		if prev == b || g.runsAfter(prev, b) {
+			return true
+		}
+	}
+	return false
+}
+
+// Run runs every chain in the group with the same arguments and waits for
+// all of them to finish. Errors returned by the chains are prefixed with
+// the chain's name and combined as they would be by Execution.Err(). A
+// chain that fails does not prevent those ordered after it from running.
+func (g *Group) Run(args ...interface{}) error {
+	g.lock.Lock()
+	names := append([]string(nil), g.names...)
+	done := make(map[string]chan struct{}, len(names))
+	for _, name := range names {
+		done[name] = make(chan struct{})
+	}
+	after := make(map[string][]string, len(g.after))
+	for name, prev := range g.after {
+		after[name] = append([]string(nil), prev...)
+	}
+	chains := make(map[string]Root, len(g.chains))
+	for name, r := range g.chains {
+		chains[name] = r
+	}
+	g.lock.Unlock()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(names))
+	wg.Add(len(names))
+	for i, name := range names {
+		go func(i int, name string) {
+			defer wg.Done()
+			defer close(done[name])
+			for _, prev := range after[name] {
+				<-done[prev]
+			}
+			if err := chains[name].Run(args...); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", name, err)
+			}
+		}(i, name)
+	}
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return combineErrors(failed)
+}
@@ -0,0 +1,54 @@
+package chain_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestGroup(t *testing.T) {
+	var lock sync.Mutex
+	var got []string
+	newChain := func(name string) chain.Root {
+		c := chain.New()
+		if _, err := c.Register(func() {
+			lock.Lock()
+			defer lock.Unlock()
+			got = append(got, name)
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	g := chain.NewGroup()
+	for _, name := range []string{"http", "db", "cache"} {
+		if err := g.Add(name, newChain(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.Add("db", chain.New()); !errors.Is(err, chain.ErrNameInUse) {
+		t.Fatalf("expected %v, got %v", chain.ErrNameInUse, err)
+	}
+	if err := g.Order("db", "cache"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Order("cache", "http"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Order("http", "db"); !errors.Is(err, chain.ErrCycle) {
+		t.Fatalf("expected %v, got %v", chain.ErrCycle, err)
+	}
+	if err := g.Order("db", "queue"); !errors.Is(err, chain.ErrUnknownChain) {
+		t.Fatalf("expected %v, got %v", chain.ErrUnknownChain, err)
+	}
+
+	if err := g.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0] != "db" || got[1] != "cache" || got[2] != "http" {
+		t.Fatalf("unexpected order %v", got)
+	}
+}
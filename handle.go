@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+// Handle refers to a single registered func, as opposed to a Predicate
+// which refers to the node it was registered with. Handles are obtained
+// by passing Capture() to Register() or one of its friends.
+type Handle struct {
+	chain *chainNode
+	e     *entry
+}
+
+// Capture stores a Handle to the first func registered by a call to
+// Register() (or one of its friends) in h.
+func Capture(h *Handle) RegisterOption {
+	return func(r *registration) {
+		*h = Handle{}
+		r.handle = h
+	}
+}
+
+// Before registers funcs to be called before the func referred to by h.
+// Unlike Predicate.Before() this only orders the new funcs relative to that
+// one func: if it shares its node with others it is first moved into a
+// node of its own so that the new funcs can run concurrently with the rest.
+//
+// Splitting the node reorders h relative to its former siblings: h now
+// runs after all of them, just as the new funcs run before it. The new
+// node keeps the dependencies (see WaitFor) and error handler of the old
+// one, but not its name, which stays with the siblings; Lookup() and
+// WaitFor() on that name no longer cover h.
+func Before(h Handle, fn ...interface{}) (Predicate, error) {
+	return h.insert(true, fn)
+}
+
+// After registers funcs to be called after the func referred to by h,
+// splitting its node as Before() does, in which case h runs before all of
+// its former siblings.
+func After(h Handle, fn ...interface{}) (Predicate, error) {
+	return h.insert(false, fn)
+}
+
+func (h Handle) insert(before bool, fn []interface{}) (Predicate, error) {
+	site := callSite()
+	if h.chain == nil {
+		return nil, siteError(site, ErrInvalidHandle)
+	}
+	defer h.chain.notify()
+	h.chain.lock.Lock()
+	defer h.chain.lock.Unlock()
+	if err := h.chain.checkSealed(); err != nil {
+		return nil, siteError(site, err)
+	}
+
+	n := h.find()
+	if n == nil {
+		return nil, siteError(site, ErrInvalidHandle)
+	}
+	if err := n.checkAnchor(before); err != nil {
+		return n, siteError(site, err)
+	}
+	funcs, reg, err := validateAll(n, fn)
+	if err == nil && reg.ordered() {
+		err = ErrOrderConflict
+	}
+	if err != nil {
+		return n, siteError(site, err)
+	}
+
+	// [...][a h b][...] becomes [...][a b new][h][...] for Before() and
+	// [...][h][a b new][...] for After().
+	var target *chainNode
+	switch {
+	case len(n.funcs) > 1:
+		var alone *chainNode
+		if before {
+			alone = n.insertAfter()
+		} else {
+			alone = n.insertBefore()
+		}
+		// alone lands on the outer side of an anchor node (the check
+		// above rules out the other side) so it becomes the anchor
+		alone.anchor, n.anchor = n.anchor, 0
+		alone.deps, alone.onError = append([]dependency(nil), n.deps...), n.onError
+		n.remove(h.e)
+		alone.funcs = append(alone.funcs, h.e)
+		alone.queue(Event{Kind: EventFuncRegistered, Node: alone, Func: funcType(h.e.fn)})
+		target = n
+	case before && n.before != nil:
+		target = n.before
+	case before:
+		target = n.insertBefore()
+	case n.after != nil:
+		target = n.after
+	default:
+		target = n.insertAfter()
+	}
+	target.addAll(funcs, site, reg)
+	return target, nil
+}
+
+// finds the node holding the handle's func, must be called with the chain
+// locked.
+func (h Handle) find() *chainNode {
+	for n := h.chain.getFirst(); n != nil; n = n.getNext() {
+		for _, e := range n.funcs {
+			if e == h.e {
+				return n
+			}
+		}
+	}
+	return nil
+}
+
+// removes a single func from the node, must be called with the chain
+// locked.
+func (cn *chainNode) remove(e *entry) {
+	for i, f := range cn.funcs {
+		if f == e {
+			copy(cn.funcs[i:], cn.funcs[i+1:])
+			cn.funcs[len(cn.funcs)-1] = nil
+			cn.funcs = cn.funcs[:len(cn.funcs)-1]
+			cn.queue(Event{Kind: EventFuncRemoved, Node: cn, Func: funcType(e.fn)})
+			return
+		}
+	}
+}
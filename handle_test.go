@@ -0,0 +1,103 @@
+package chain_test
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestHandles(t *testing.T) {
+	c := chain.New()
+	var lock sync.Mutex
+	var got []string
+	record := func(name string) func() {
+		return func() {
+			lock.Lock()
+			defer lock.Unlock()
+			got = append(got, name)
+		}
+	}
+	var h chain.Handle
+	pred, err := c.Register(record("h"), chain.Capture(&h))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// unrelated is blocked until h returns so a func that is only ordered
+	// relative to h must not wait for it
+	unblock := make(chan struct{})
+	if _, err = pred.Register(func() { <-unblock }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = chain.After(h, func() {
+		record("after")()
+		close(unblock)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = chain.Before(h, record("before")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[0] != "before" || got[1] != "h" || got[2] != "after" {
+		t.Fatalf("unexpected call order %v", got)
+	}
+
+	if _, err = chain.After(chain.Handle{}, func() {}); !errors.Is(err, chain.ErrInvalidHandle) {
+		t.Fatalf("expected %v, got %v", chain.ErrInvalidHandle, err)
+	}
+}
+
+func TestHandleSplit(t *testing.T) {
+	var ran []string
+	record := func(name string) func() {
+		return func() { ran = append(ran, name) }
+	}
+	failure := errors.New("failure")
+	c := chain.New(chain.WithWorkers(1))
+	p, err := c.Register(record("sibling"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var h chain.Handle
+	if _, err = p.Register(func() error {
+		ran = append(ran, "h")
+		return failure
+	}, chain.Capture(&h)); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.SetName("node"); err != nil {
+		t.Fatal(err)
+	}
+	handled := 0
+	if err = p.OnError(func(chain.FuncInfo, error) chain.Decision {
+		handled++
+		return chain.Continue
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// h moves behind its former sibling, which keeps the node's name,
+	// but is still handled by the node's error handler
+	before, err := chain.Before(h, record("before"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.Run(); err != failure {
+		t.Fatalf("expected %v, got %v", failure, err)
+	}
+	if want := []string{"sibling", "before", "h"}; !reflect.DeepEqual(ran, want) {
+		t.Fatalf("ran %v, want %v", ran, want)
+	}
+	if n, ok := c.Lookup("node"); !ok || n != before {
+		t.Fatal("name did not stay with the node h was split from")
+	}
+	if handled != 1 {
+		t.Fatalf("error handler called %d times, want 1", handled)
+	}
+}
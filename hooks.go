@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+func (cn *chainNode) OnRunStart(fn func(*RunInfo)) {
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	// never append in place, snapshots and clones share the slice
+	cn.opts.runStart = append(cn.opts.runStart[:len(cn.opts.runStart):len(cn.opts.runStart)], fn)
+	cn.opts.cache.invalidate()
+}
+
+func (cn *chainNode) OnRunDone(fn func(*RunInfo, error)) {
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	cn.opts.runDone = append(cn.opts.runDone[:len(cn.opts.runDone):len(cn.opts.runDone)], fn)
+	cn.opts.cache.invalidate()
+}
+
+func (e *Execution) started() {
+	for _, fn := range e.snap.opts.runStart {
+		fn(e.info)
+	}
+}
+
+// called once every worker has exited
+func (e *Execution) finished() {
+	if len(e.snap.opts.runDone) == 0 {
+		return
+	}
+	err := e.err
+	if err == nil {
+		e.lock.Lock()
+		err = combineErrors(e.errors)
+		e.lock.Unlock()
+	}
+	for _, fn := range e.snap.opts.runDone {
+		fn(e.info, err)
+	}
+}
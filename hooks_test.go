@@ -0,0 +1,35 @@
+package chain_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestRunHooks(t *testing.T) {
+	var got []string
+	fail := errors.New("fail")
+	c := chain.NewTyped(func() error { return nil })
+	c.OnRunStart(func(info *chain.RunInfo) {
+		info.Set("tx", "open")
+		got = append(got, "start")
+	})
+	c.OnRunDone(func(info *chain.RunInfo, err error) {
+		tx, _ := info.Get("tx")
+		if err != fail || tx != "open" {
+			t.Errorf("unexpected done hook state %v, %v", err, tx)
+		}
+		got = append(got, "done")
+	})
+	c.Register(func() error {
+		got = append(got, "run")
+		return fail
+	})
+	if err := c.Run(); err != fail {
+		t.Fatalf("expected run to fail, got %v", err)
+	}
+	if len(got) != 3 || got[0] != "start" || got[1] != "run" || got[2] != "done" {
+		t.Fatalf("unexpected hook order %v", got)
+	}
+}
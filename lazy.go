@@ -0,0 +1,256 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// Lazy returns a Root whose underlying chain is not created until it is
+// first used. At that point a new chain is created with New(opts...) and
+// passed to build, exactly once, before whatever method was called
+// proceeds. This allows package level chains to be declared without
+// depending on package initialization order:
+//
+//	var Startup = chain.Lazy(func(r chain.Root) {
+//	    r.Register(otherpkg.Init)
+//	})
+//
+// build must use the Root it is passed and not the one returned by Lazy.
+func Lazy(build func(Root), opts ...Option) Root {
+	return &lazyRoot{build: build, opts: opts}
+}
+
+type lazyRoot struct {
+	once  sync.Once
+	build func(Root)
+	opts  []Option
+	root  *chainNode
+}
+
+func (l *lazyRoot) get() *chainNode {
+	l.once.Do(func() {
+		root := New(l.opts...).(*chainNode)
+		if l.build != nil {
+			l.build(root)
+		}
+		l.root = root
+	})
+	return l.root
+}
+
+// asNode returns the root node behind r, initializing lazy chains.
+func asNode(r Root) (*chainNode, bool) {
+	switch n := r.(type) {
+	case *chainNode:
+		return n, true
+	case *lazyRoot:
+		return n.get(), true
+	}
+	return nil, false
+}
+
+func (l *lazyRoot) Register(fn ...interface{}) (Predicate, error) {
+	return l.get().Register(fn...)
+}
+
+func (l *lazyRoot) RegisterMap(fns map[string]interface{}) (Predicate, error) {
+	return l.get().RegisterMap(fns)
+}
+
+func (l *lazyRoot) Waiter() (Waiter, error) {
+	return l.get().Waiter()
+}
+
+func (l *lazyRoot) Iterate(wg ...*sync.WaitGroup) <-chan interface{} {
+	return l.get().Iterate(wg...)
+}
+
+func (l *lazyRoot) FuncsSlice() []interface{} {
+	return l.get().FuncsSlice()
+}
+
+func (l *lazyRoot) Nodes() []Call {
+	return l.get().Nodes()
+}
+
+func (l *lazyRoot) NodesMatching(pattern string) ([]Predicate, error) {
+	return l.get().NodesMatching(pattern)
+}
+
+func (l *lazyRoot) RunFanOut(sets ArgSets) error {
+	return l.get().RunFanOut(sets)
+}
+
+func (l *lazyRoot) StartFanOut(sets ArgSets) *Execution {
+	return l.get().StartFanOut(sets)
+}
+
+func (l *lazyRoot) RunMatching(pattern string, args ...interface{}) error {
+	return l.get().RunMatching(pattern, args...)
+}
+
+func (l *lazyRoot) Head() Predicate {
+	return l.get().Head()
+}
+
+func (l *lazyRoot) Tail() Predicate {
+	return l.get().Tail()
+}
+
+func (l *lazyRoot) Middle() Predicate {
+	return l.get().Middle()
+}
+
+func (l *lazyRoot) Len() int {
+	return l.get().Len()
+}
+
+func (l *lazyRoot) Dump(w io.Writer) error {
+	return l.get().Dump(w)
+}
+
+func (l *lazyRoot) Walk(fn func(Predicate, []FuncInfo) error) error {
+	return l.get().Walk(fn)
+}
+
+func (l *lazyRoot) Funcs() []FuncInfo {
+	return l.get().Funcs()
+}
+
+func (l *lazyRoot) Lookup(name string) (Predicate, bool) {
+	return l.get().Lookup(name)
+}
+
+func (l *lazyRoot) FindFunc(fn interface{}) (Predicate, bool) {
+	return l.get().FindFunc(fn)
+}
+
+func (l *lazyRoot) RegisterMethods(rcvr interface{}, prefixes ...string) (int, error) {
+	return l.get().RegisterMethods(rcvr, prefixes...)
+}
+
+func (l *lazyRoot) Validator() Validating {
+	return l.get().Validator()
+}
+
+func (l *lazyRoot) SetValidator(v Validating) error {
+	return l.get().SetValidator(v)
+}
+
+func (l *lazyRoot) IterateAll() <-chan Call {
+	return l.get().IterateAll()
+}
+
+func (l *lazyRoot) Run(args ...interface{}) error {
+	return l.get().Run(args...)
+}
+
+func (l *lazyRoot) RunFiltered(filter func(interface{}, []interface{}) bool, args ...interface{}) error {
+	return l.get().RunFiltered(filter, args...)
+}
+
+func (l *lazyRoot) RunFrom(p Predicate, args ...interface{}) error {
+	return l.get().RunFrom(p, args...)
+}
+
+func (l *lazyRoot) RunUntil(p Predicate, args ...interface{}) error {
+	return l.get().RunUntil(p, args...)
+}
+
+func (l *lazyRoot) RunReduce(reduce func(acc, result interface{}) interface{}, args ...interface{}) (interface{}, error) {
+	return l.get().RunReduce(reduce, args...)
+}
+
+func (l *lazyRoot) RunContext(ctx context.Context, args ...interface{}) error {
+	return l.get().RunContext(ctx, args...)
+}
+
+func (l *lazyRoot) Resume(args ...interface{}) error {
+	return l.get().Resume(args...)
+}
+
+func (l *lazyRoot) Start(args ...interface{}) *Execution {
+	return l.get().Start(args...)
+}
+
+func (l *lazyRoot) StartFiltered(filter func(interface{}, []interface{}) bool, args ...interface{}) *Execution {
+	return l.get().StartFiltered(filter, args...)
+}
+
+func (l *lazyRoot) RunSelect(sel func(FuncInfo, []interface{}) Decision, args ...interface{}) error {
+	return l.get().RunSelect(sel, args...)
+}
+
+func (l *lazyRoot) StartSelect(sel func(FuncInfo, []interface{}) Decision, args ...interface{}) *Execution {
+	return l.get().StartSelect(sel, args...)
+}
+
+func (l *lazyRoot) StartContext(ctx context.Context, args ...interface{}) *Execution {
+	return l.get().StartContext(ctx, args...)
+}
+
+func (l *lazyRoot) StartResume(args ...interface{}) *Execution {
+	return l.get().StartResume(args...)
+}
+
+func (l *lazyRoot) StartStepped(args ...interface{}) *Execution {
+	return l.get().StartStepped(args...)
+}
+
+func (l *lazyRoot) SetArgAdapter(fn func([]interface{}) ([]interface{}, error)) error {
+	return l.get().SetArgAdapter(fn)
+}
+
+func (l *lazyRoot) SetPostValidator(fn func([]Result) error) error {
+	return l.get().SetPostValidator(fn)
+}
+
+func (l *lazyRoot) Snapshot() *Snapshot {
+	return l.get().Snapshot()
+}
+
+func (l *lazyRoot) OnRunStart(fn func(*RunInfo)) {
+	l.get().OnRunStart(fn)
+}
+
+func (l *lazyRoot) OnRunDone(fn func(*RunInfo, error)) {
+	l.get().OnRunDone(fn)
+}
+
+func (l *lazyRoot) OnChange(fn func(Event)) {
+	l.get().OnChange(fn)
+}
+
+func (l *lazyRoot) Seal() {
+	l.get().Seal()
+}
+
+func (l *lazyRoot) Sealed() bool {
+	return l.get().Sealed()
+}
+
+func (l *lazyRoot) Batch(fn func(Builder) error) error {
+	return l.get().Batch(fn)
+}
+
+func (l *lazyRoot) Trace() []TraceEvent {
+	return l.get().Trace()
+}
+
+func (l *lazyRoot) Clone() Root {
+	return l.get().Clone()
+}
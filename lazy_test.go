@@ -0,0 +1,27 @@
+package chain_test
+
+import (
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestLazy(t *testing.T) {
+	var built, ran int
+	c := chain.Lazy(func(r chain.Root) {
+		built++
+		r.Register(func() { ran++ })
+	})
+	if built != 0 {
+		t.Fatal("lazy chain built before first use")
+	}
+	if _, err := c.Register(func() { ran++ }); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if built != 1 || ran != 2 {
+		t.Fatalf("built %d times, ran %d funcs", built, ran)
+	}
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+// The names of the canonical phases created by NewLifecycle(), in the
+// order they run.
+const (
+	PhaseConfigure = "Configure"
+	PhaseInit      = "Init"
+	PhaseStart     = "Start"
+	PhaseReady     = "Ready"
+	PhaseDrain     = "Drain"
+	PhaseStop      = "Stop"
+)
+
+var lifecyclePhases = []string{PhaseConfigure, PhaseInit, PhaseStart, PhaseReady, PhaseDrain, PhaseStop}
+
+// Lifecycle is a chain created with a named node for each of the canonical
+// phases of a program's life, so that independent packages can all
+// register relative to the same anchors. Funcs can be registered with the
+// phases themselves or Before()/After() them as usual.
+type Lifecycle struct {
+	Root
+	phases map[string]Predicate
+}
+
+// Returns a new chain with the canonical lifecycle phases already in place
+func NewLifecycle(opts ...Option) *Lifecycle {
+	root := New(opts...).(*chainNode)
+	l := &Lifecycle{Root: root, phases: make(map[string]Predicate, len(lifecyclePhases))}
+	root.lock.Lock()
+	n := root
+	for i, name := range lifecyclePhases {
+		if i > 0 {
+			n = n.insertAfter()
+		}
+		n.name = name
+		l.phases[name] = n
+	}
+	root.lock.Unlock()
+	return l
+}
+
+// Phase returns the node of the named phase.
+func (l *Lifecycle) Phase(name string) (Predicate, bool) {
+	p, ok := l.phases[name]
+	return p, ok
+}
+
+// Accessors for each of the phases. They are named so as not to clash
+// with the methods of Root (such as Start()).
+func (l *Lifecycle) ConfigurePhase() Predicate { return l.phases[PhaseConfigure] }
+func (l *Lifecycle) InitPhase() Predicate      { return l.phases[PhaseInit] }
+func (l *Lifecycle) StartPhase() Predicate     { return l.phases[PhaseStart] }
+func (l *Lifecycle) ReadyPhase() Predicate     { return l.phases[PhaseReady] }
+func (l *Lifecycle) DrainPhase() Predicate     { return l.phases[PhaseDrain] }
+func (l *Lifecycle) StopPhase() Predicate      { return l.phases[PhaseStop] }
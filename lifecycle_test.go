@@ -0,0 +1,49 @@
+package chain_test
+
+import (
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestLifecycle(t *testing.T) {
+	l := chain.NewLifecycle(chain.WithWorkers(1))
+	var got []string
+	record := func(name string) func() {
+		return func() { got = append(got, name) }
+	}
+	// registered out of order, as independent packages would
+	if _, err := l.StopPhase().Register(record("stop")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.ReadyPhase().Before(record("before ready")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.ConfigurePhase().Register(record("configure")); err != nil {
+		t.Fatal(err)
+	}
+	p, ok := l.Phase(chain.PhaseStart)
+	if !ok || p != l.StartPhase() {
+		t.Fatal("Phase() and StartPhase() disagree")
+	}
+	if _, err := p.Register(record("start")); err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := l.Lookup(chain.PhaseDrain); !ok || n != l.DrainPhase() {
+		t.Fatal("phases should be named nodes")
+	}
+
+	var root chain.Root = l
+	if err := root.Run(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"configure", "start", "before ready", "stop"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"context"
+	"sync"
+)
+
+// Locals holds values shared by the funcs of a single node during a
+// single run, which may be running concurrently. Every node of every
+// context run (see RunContext) has its own Locals, which funcs get from
+// their context with LocalsFrom(). Values which need to be seen by later
+// nodes belong to the run instead, see RunInfo.Set().
+type Locals struct {
+	lock   sync.Mutex
+	values map[interface{}]interface{}
+}
+
+type localsKey struct{}
+
+// LocalsFrom returns the Locals of the node whose func was passed ctx by
+// a run, or nil.
+func LocalsFrom(ctx context.Context) *Locals {
+	l, _ := ctx.Value(localsKey{}).(*Locals)
+	return l
+}
+
+// Set stores a value under key.
+func (l *Locals) Set(key, value interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.values == nil {
+		l.values = make(map[interface{}]interface{})
+	}
+	l.values[key] = value
+}
+
+// Get returns the value stored under key.
+func (l *Locals) Get(key interface{}) (interface{}, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	v, ok := l.values[key]
+	return v, ok
+}
+
+// Update atomically replaces the value stored under key with the result
+// of calling fn with the current value (and whether there is one),
+// returning the new value. Other funcs in the node block while fn runs.
+func (l *Locals) Update(key interface{}, fn func(interface{}, bool) interface{}) interface{} {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	old, ok := l.values[key]
+	v := fn(old, ok)
+	if l.values == nil {
+		l.values = make(map[interface{}]interface{})
+	}
+	l.values[key] = v
+	return v
+}
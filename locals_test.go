@@ -0,0 +1,37 @@
+package chain_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestLocals(t *testing.T) {
+	var max int32
+	count := func(ctx context.Context) {
+		n := chain.LocalsFrom(ctx).Update("count", func(v interface{}, ok bool) interface{} {
+			if !ok {
+				return 1
+			}
+			return v.(int) + 1
+		}).(int)
+		if n == 3 {
+			atomic.StoreInt32(&max, int32(n))
+		}
+	}
+	var leaked bool
+	c := chain.New()
+	p, _ := c.Register(count, count, count)
+	p.After(func(ctx context.Context) {
+		// locals belong to a single node
+		_, leaked = chain.LocalsFrom(ctx).Get("count")
+	})
+	if err := c.RunContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if max != 3 || leaked {
+		t.Fatalf("locals not shared by exactly one node: %d, %v", max, leaked)
+	}
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+func (cn *chainNode) Name() string {
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	return cn.name
+}
+
+func (cn *chainNode) SetName(name string) error {
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	if err := cn.checkSealed(); err != nil {
+		return err
+	}
+	if name == cn.name {
+		return nil
+	}
+	if err := cn.checkName(name); err != nil {
+		return err
+	}
+	cn.name = name
+	cn.opts.cache.invalidate()
+	return nil
+}
+
+func (cn *chainNode) Lookup(name string) (Predicate, bool) {
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	if n := cn.lookup(name); n != nil {
+		return n, true
+	}
+	return nil, false
+}
+
+// finds the node with the given name or holding a func registered under
+// it, must be called with the chain locked.
+func (cn *chainNode) lookup(name string) *chainNode {
+	if name == "" {
+		return nil
+	}
+	for n := cn.getFirst(); n != nil; n = n.getNext() {
+		if n.name == name {
+			return n
+		}
+		for _, e := range n.funcs {
+			if e.name == name {
+				return n
+			}
+		}
+	}
+	return nil
+}
+
+// returns an error if name is already used by a node or func, or is a
+// malformed path, must be called with the chain locked.
+func (cn *chainNode) checkName(name string) error {
+	if !validName(name) {
+		return fmt.Errorf("%q: %w", name, ErrInvalidName)
+	}
+	if cn.lookup(name) != nil {
+		return fmt.Errorf("%q: %w", name, ErrNameInUse)
+	}
+	return nil
+}
+
+// names are either flat or slash separated paths, such as "db/migrate",
+// none of whose elements may be empty
+func validName(name string) bool {
+	if !strings.Contains(name, "/") {
+		return true
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if elem == "" {
+			return false
+		}
+	}
+	return true
+}
+
+func (cn *chainNode) NodesMatching(pattern string) ([]Predicate, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	var nodes []Predicate
+	for n := cn.getFirst(); n != nil; n = n.getNext() {
+		if ok, _ := path.Match(pattern, n.name); ok && n.name != "" {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes, nil
+}
+
+func (cn *chainNode) RunMatching(pattern string, args ...interface{}) error {
+	s, err := cn.Snapshot().Matching(pattern)
+	if err != nil {
+		return cn.opts.handle(err)
+	}
+	return s.Run(args...)
+}
+
+// Matching returns a copy of the snapshot holding only the nodes whose
+// names match a glob pattern, see Root.NodesMatching().
+func (s *Snapshot) Matching(pattern string) (*Snapshot, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	m := *s
+	m.nodes = nil
+	for _, n := range s.nodes {
+		if ok, _ := path.Match(pattern, n.name); ok && n.name != "" {
+			m.nodes = append(m.nodes, n)
+		}
+	}
+	return &m, nil
+}
+
+// RegisterMap registers funcs in name order so that the layout of the node
+// doesn't depend on map iteration order. Nothing is registered unless
+// every func is valid and every name is unused.
+func (cn *chainNode) RegisterMap(fns map[string]interface{}) (Predicate, error) {
+	site := callSite()
+	names := make([]string, 0, len(fns))
+	for name := range fns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	funcs := make([]interface{}, len(names))
+	for i, name := range names {
+		f, err := validate(cn, fns[name])
+		if err != nil {
+			return cn, siteError(site, fmt.Errorf("%q: %w", name, err))
+		}
+		funcs[i] = f
+	}
+
+	defer cn.notify()
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	if err := cn.checkSealed(); err != nil {
+		return cn, siteError(site, err)
+	}
+	for _, name := range names {
+		if err := cn.checkName(name); err != nil {
+			return cn, siteError(site, err)
+		}
+	}
+	for i, f := range funcs {
+		if f != nil {
+			cn.add(f, site).name = names[i]
+		}
+	}
+	return cn, nil
+}
@@ -0,0 +1,106 @@
+package chain_test
+
+import (
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestRegisterMap(t *testing.T) {
+	var calls int32
+	inc := func(*testing.T) { atomic.AddInt32(&calls, 1) }
+
+	c := chain.NewTyped(TestFunc(nil))
+	p, err := c.Register(inc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plugins, err := p.After(inc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = plugins.SetName("plugins"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = plugins.RegisterMap(map[string]interface{}{
+		"db":    inc,
+		"cache": inc,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"plugins", "db", "cache"} {
+		if n, ok := c.Lookup(name); !ok || n != plugins {
+			t.Fatalf("%q did not lead to the plugins node", name)
+		}
+	}
+	if _, ok := c.Lookup("missing"); ok {
+		t.Fatal("found a name that was never registered")
+	}
+	c.Run(t)
+	if calls != 4 {
+		t.Fatalf("expected 4 calls, got %d", calls)
+	}
+
+	_, err = p.RegisterMap(map[string]interface{}{"new": inc, "db": inc})
+	if !errors.Is(err, chain.ErrNameInUse) {
+		t.Fatalf("expected duplicate name to be rejected, got %v", err)
+	}
+	if err = p.SetName("cache"); !errors.Is(err, chain.ErrNameInUse) {
+		t.Fatalf("expected duplicate node name to be rejected, got %v", err)
+	}
+	if l := c.Len(); l != 4 {
+		t.Fatalf("failed registration changed chain length to %d", l)
+	}
+}
+
+func TestNodesMatching(t *testing.T) {
+	var ran []string
+	record := func(name string) func() {
+		return func() { ran = append(ran, name) }
+	}
+	c := chain.New(chain.WithWorkers(1))
+	p, err := c.Register(record("db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.SetName("db"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"db/connect", "cache/warm", "db/migrate", "db/migrate/up"} {
+		if p, err = p.After(record(name)); err != nil {
+			t.Fatal(err)
+		}
+		if err = p.SetName(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = p.SetName("db//up"); !errors.Is(err, chain.ErrInvalidName) {
+		t.Errorf("SetName(%q) returned %v", "db//up", err)
+	}
+
+	nodes, err := c.NodesMatching("db/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, n := range nodes {
+		names = append(names, n.Name())
+	}
+	if want := []string{"db/connect", "db/migrate"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("matched %v, want %v", names, want)
+	}
+	if _, err = c.NodesMatching("db/["); err == nil {
+		t.Error("bad pattern was accepted")
+	}
+
+	if err = c.RunMatching("db/*"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"db/connect", "db/migrate"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+}
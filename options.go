@@ -0,0 +1,230 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"io"
+	"time"
+)
+
+// Option configures chain-wide behavior and is passed to one of the New*
+// constructors. Options are shared by every node in a chain and are copied
+// when a chain is cloned.
+type Option func(*options)
+
+type options struct {
+	workers       int
+	iterBuffer    int
+	waiter        func() Waiter
+	nodeDelay     time.Duration
+	grace         time.Duration
+	drain         time.Duration
+	deterministic bool
+	seed          int64
+	statsCallback func(*Stats)
+
+	watchdogThreshold time.Duration
+	watchdog          func(interface{}, time.Duration)
+
+	stopOnError  bool
+	recovery     RecoveryPolicy
+	strict       bool
+	prefixFuncs  bool
+	errorHandler func(error)
+	checkpoints  CheckpointStore
+	profile      bool
+	profileName  string
+	argAdapter   func([]interface{}) ([]interface{}, error)
+	boundArgs    []interface{}
+	argPolicy    ArgPolicy
+	output       func(FuncInfo) io.Writer
+	anchors      AnchorPolicy
+	chaos        *Chaos
+
+	postValidator func([]Result) error
+
+	// chain-wide state, protected by the chain lock
+	listeners []func(Event)
+	runStart  []func(*RunInfo)
+	runDone   []func(*RunInfo, error)
+	pending   []Event
+	sealed    bool
+
+	// chain-wide state with its own lock
+	trace    *traceRing
+	debounce *debouncer
+	flight   *flight
+
+	// the current snapshot of the chain, read without locking but
+	// rebuilt under the chain lock by the first run after a modification
+	cache *snapCache
+
+	// storage for the chain's nodes and funcs, protected by the chain lock
+	arena *arena
+
+	// named nodes that have completed at least once, see WaitFor()
+	latches *latchSet
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{workers: DefaultWorkers, iterBuffer: IterateBuffered, cache: &snapCache{}, latches: newLatchSet(), arena: &arena{}}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	return o
+}
+
+func (o *options) clone() *options {
+	if o == nil {
+		return newOptions(nil)
+	}
+	c := *o
+	return &c
+}
+
+// DefaultWorkers is the maximum number of funcs from a single node that
+// will execute concurrently unless WithWorkers is used. The default of zero
+// runs every func of a node at once, as funcs sharing a node may wait on
+// each other.
+var DefaultWorkers = 0
+
+// WithWorkers sets the maximum number of worker goroutines each run uses to
+// execute the funcs of a node concurrently. Zero (or less) means one worker
+// per func in the widest node, which is required if funcs in the same node
+// must rendezvous with each other.
+func WithWorkers(n int) Option {
+	return func(o *options) {
+		o.workers = n
+	}
+}
+
+// IterateBuffered is the default Iterate()/IterateAll() buffering, see
+// WithIterateBuffer.
+const IterateBuffered = -1
+
+// WithIterateBuffer sets the size of the channels returned by Iterate()
+// and IterateAll(). By default (IterateBuffered) they are large enough
+// to hold every element and are already filled and closed when returned.
+// Otherwise the elements are taken from a snapshot of the chain when the
+// channel is returned and fed into it from a goroutine, so that zero gives
+// strict backpressure: each element is only sent once the previous one
+// has been received. Such channels must be drained or the goroutine
+// leaks. WaitGroups passed to Iterate() are always incremented up front.
+func WithIterateBuffer(n int) Option {
+	return func(o *options) {
+		if n < 0 {
+			n = IterateBuffered
+		}
+		o.iterBuffer = n
+	}
+}
+
+// WithNodeDelay inserts a pause of duration d after each node's funcs have
+// all completed and before the funcs of the next node are released. Useful
+// when subsequent phases need settle time (hardware init, etc).
+func WithNodeDelay(d time.Duration) Option {
+	return func(o *options) {
+		o.nodeDelay = d
+	}
+}
+
+// WithStatsCallback arranges for fn to be called with the statistics of
+// every run once it completes. This is the only way to get at statistics
+// when using the synchronous Run() and RunFiltered().
+func WithStatsCallback(fn func(*Stats)) Option {
+	return func(o *options) {
+		o.statsCallback = fn
+	}
+}
+
+// WithWatchdog arranges for fn to be called with the identity of any func
+// (as would be passed to a RunFiltered filter) that has been running for
+// longer than threshold, along with how long it has been running. The
+// offending func is not interrupted.
+func WithWatchdog(threshold time.Duration, fn func(interface{}, time.Duration)) Option {
+	return func(o *options) {
+		o.watchdogThreshold = threshold
+		o.watchdog = fn
+	}
+}
+
+// WithStopOnError makes the running of each node conditional on all
+// preceding nodes succeeding. Once any func returns a non-nil error as its
+// final result (typically from a chain created with NewTyped() using a
+// func(...) error type) the funcs of all subsequent nodes are skipped.
+// Funcs in the same node as the failure are unaffected.
+func WithStopOnError() Option {
+	return func(o *options) {
+		o.stopOnError = true
+	}
+}
+
+// WithStrict enables strict registration diagnostics. Registering a nil
+// func is rejected and, for typed chains, funcs are checked parameter by
+// parameter (and result by result) against the chain's type so that any
+// mismatch is reported in detail at registration time rather than as a
+// panic deep inside a run.
+func WithStrict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// WithTrace enables an in-memory trace of the last n func start and end
+// events across all runs of the chain, retrievable with Root.Trace().
+func WithTrace(n int) Option {
+	return func(o *options) {
+		o.trace = newTraceRing(n)
+	}
+}
+
+// WithDebounce makes Root.Run() wait until window has passed without
+// another call to Run() before actually running the chain, so that a burst
+// of calls results in a single run. The run uses the arguments of the last
+// call and every call in the burst returns its result.
+func WithDebounce(window time.Duration) Option {
+	return func(o *options) {
+		o.debounce = &debouncer{window: window}
+	}
+}
+
+// WithBoundArgs fixes the leading arguments of every func call, so that
+// Run() and friends only need to be passed the remainder. Bound arguments
+// follow any context.Context injected by RunContext() and are appended to
+// after any arg adapter (see SetArgAdapter) has been applied. They may
+// include Placeholders, such as NodeName, to pass each func a value
+// particular to its call.
+func WithBoundArgs(args ...interface{}) Option {
+	return func(o *options) {
+		o.boundArgs = args
+	}
+}
+
+// WithErrorHandler sets a func to be called with any error returned by
+// Run() and friends, which is convenient when runs are started from many
+// places that can't all be bothered to check for errors.
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *options) {
+		o.errorHandler = fn
+	}
+}
+
+func (o *options) handle(err error) error {
+	if err != nil && o.errorHandler != nil {
+		o.errorHandler(err)
+	}
+	return err
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"sync"
+)
+
+// WithOutput gives every func called by a context run (see RunContext) its
+// own output stream, retrieved from its context with Output(). Output is
+// buffered until the func's node completes and is then written, one func
+// at a time in registration order, to the writer returned by factory for
+// that func. Output from funcs running concurrently therefore never
+// interleaves. PrefixWriter is useful for telling the funcs apart.
+func WithOutput(factory func(FuncInfo) io.Writer) Option {
+	return func(o *options) {
+		o.output = factory
+	}
+}
+
+type outputKey struct{}
+
+// Output returns the output stream of the func being passed ctx, or
+// io.Discard if the chain wasn't created using WithOutput. The stream may
+// be written to concurrently but not after the func returns; output from
+// a func abandoned by a drain run (see WithDrain) is discarded once its
+// node completes.
+func Output(ctx context.Context) io.Writer {
+	if w, ok := ctx.Value(outputKey{}).(io.Writer); ok {
+		return w
+	}
+	return io.Discard
+}
+
+// outputBuffer holds a func's output until its node completes. Once taken
+// any further output, from a func abandoned by a drain run say, is
+// discarded.
+type outputBuffer struct {
+	lock  sync.Mutex
+	buf   bytes.Buffer
+	taken bool
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.taken {
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// returns the buffered output, which is no longer written to
+func (b *outputBuffer) take() []byte {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.taken = true
+	return b.buf.Bytes()
+}
+
+// writes the output of every func in a completed phase, in registration
+// order even if the phase's calls were shuffled
+func (e *Execution) flushOutput(p *phase) {
+	e.lock.Lock()
+	outputs := append([]*outputBuffer(nil), p.output...)
+	e.lock.Unlock()
+	order := make(map[*entry]int, len(p.calls))
+	for i, ent := range e.snap.nodes[p.node].funcs {
+		order[ent] = i
+	}
+	calls := make([]int, len(p.calls))
+	for i := range calls {
+		calls[i] = i
+	}
+	sort.Slice(calls, func(i, j int) bool {
+		return order[p.calls[calls[i]]] < order[p.calls[calls[j]]]
+	})
+	for _, i := range calls {
+		out := outputs[i]
+		if out == nil {
+			continue
+		}
+		if data := out.take(); len(data) > 0 {
+			if w := e.snap.opts.output(p.calls[i].info(p.node)); w != nil {
+				w.Write(data)
+			}
+		}
+	}
+}
+
+// PrefixWriter returns a writer that writes to w, prefixing every line
+// with prefix.
+func PrefixWriter(w io.Writer, prefix string) io.Writer {
+	return &prefixWriter{w: w, prefix: []byte(prefix), bol: true}
+}
+
+type prefixWriter struct {
+	w      io.Writer
+	prefix []byte
+	bol    bool
+}
+
+func (pw *prefixWriter) Write(p []byte) (int, error) {
+	var out []byte
+	for _, c := range p {
+		if pw.bol {
+			out = append(out, pw.prefix...)
+		}
+		out = append(out, c)
+		pw.bol = c == '\n'
+	}
+	if _, err := pw.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
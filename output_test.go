@@ -0,0 +1,111 @@
+package chain_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestOutput(t *testing.T) {
+	var buf bytes.Buffer
+	c := chain.New(chain.WithOutput(func(fi chain.FuncInfo) io.Writer {
+		return chain.PrefixWriter(&buf, fi.Name+": ")
+	}))
+	p, err := c.RegisterMap(map[string]interface{}{
+		"a": func(ctx context.Context) { fmt.Fprintln(chain.Output(ctx), "one\ntwo") },
+		"b": func(ctx context.Context) { fmt.Fprintln(chain.Output(ctx), "three") },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.After(func(ctx context.Context) { fmt.Fprint(chain.Output(ctx), "unnamed") })
+	if err = c.RunContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := "a: one\na: two\nb: three\n: unnamed"
+	if got := buf.String(); got != want {
+		t.Fatalf("unexpected output:\n%s", got)
+	}
+	if chain.Output(context.Background()) != io.Discard {
+		t.Fatal("expected discarded output outside of a run")
+	}
+}
+
+func TestOutputDrained(t *testing.T) {
+	var lock sync.Mutex
+	var buf bytes.Buffer
+	c := chain.New(chain.WithDrain(20*time.Millisecond), chain.WithOutput(func(chain.FuncInfo) io.Writer {
+		return writerFunc(func(p []byte) (int, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return buf.Write(p)
+		})
+	}))
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	if _, err := c.Register(func(ctx context.Context) {
+		defer close(stopped)
+		w := chain.Output(ctx)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				fmt.Fprintln(w, "still going")
+			}
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RunContext(context.Background()); !errors.Is(err, chain.ErrDrained) {
+		t.Fatalf("expected %v, got %v", chain.ErrDrained, err)
+	}
+	lock.Lock()
+	n := buf.Len()
+	lock.Unlock()
+
+	// the abandoned func keeps writing but nothing more comes out
+	time.Sleep(10 * time.Millisecond)
+	close(stop)
+	<-stopped
+	lock.Lock()
+	defer lock.Unlock()
+	if n == 0 || buf.Len() != n {
+		t.Fatalf("flushed %d bytes, %d after the func stopped", n, buf.Len())
+	}
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+func TestOutputOrder(t *testing.T) {
+	var buf bytes.Buffer
+	c := chain.New(chain.WithDeterministic(1), chain.WithOutput(func(chain.FuncInfo) io.Writer {
+		return &buf
+	}))
+	p := c.Head()
+	for i := 0; i < 10; i++ {
+		i := i
+		if _, err := p.Register(func(ctx context.Context) {
+			fmt.Fprint(chain.Output(ctx), i)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.RunContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "0123456789" {
+		t.Fatalf("output flushed as %q, want registration order", got)
+	}
+}
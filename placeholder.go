@@ -0,0 +1,110 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Placeholder stands in for an argument whose value is only known once a
+// func is about to be called. Placeholders can be passed to Run() and
+// friends or bound with WithBoundArgs, and each func is passed the value
+// substituted for it at the time of its call. A value of the wrong type
+// for a typed chain fails the func rather than the run.
+type Placeholder struct {
+	kind placeholderKind
+	n    int
+}
+
+type placeholderKind int
+
+const (
+	placeholderArg placeholderKind = iota + 1
+	placeholderRunID
+	placeholderNodeName
+)
+
+var (
+	// RunID is replaced by the ID of the run (see RunInfo), a uint64.
+	RunID = Placeholder{kind: placeholderRunID}
+	// NodeName is replaced by the name of the node the func belongs to.
+	NodeName = Placeholder{kind: placeholderNodeName}
+)
+
+// Arg is replaced by the nth argument passed to the run, after any arg
+// adapter has been applied but not counting bound arguments.
+func Arg(n int) Placeholder {
+	return Placeholder{kind: placeholderArg, n: n}
+}
+
+func (p Placeholder) String() string {
+	switch p.kind {
+	case placeholderArg:
+		return fmt.Sprintf("chain.Arg(%d)", p.n)
+	case placeholderRunID:
+		return "chain.RunID"
+	case placeholderNodeName:
+		return "chain.NodeName"
+	}
+	return "chain.Placeholder(?)"
+}
+
+// returns the positions of any placeholders among a run's arguments
+func placeholders(args []interface{}) (at []int) {
+	for i, v := range args {
+		if _, ok := v.(Placeholder); ok {
+			at = append(at, i)
+		}
+	}
+	return
+}
+
+func (e *Execution) placeholderValue(p Placeholder, c call, a *argSet) (interface{}, error) {
+	switch p.kind {
+	case placeholderArg:
+		if p.n < 0 || p.n >= len(a.run) {
+			return nil, fmt.Errorf("%v: run has %d argument(s)", p, len(a.run))
+		}
+		return a.run[p.n], nil
+	case placeholderRunID:
+		return e.info.ID, nil
+	case placeholderNodeName:
+		return e.snap.nodes[c.node].name, nil
+	}
+	return nil, fmt.Errorf("%v is not a valid placeholder", p)
+}
+
+// replaces the placeholders among a run's arguments for a single call,
+// returning the call's own copy of both the raw and reflected arguments
+func (e *Execution) substitute(c call, a *argSet, in []reflect.Value) ([]reflect.Value, []interface{}, error) {
+	vals := append([]reflect.Value(nil), in...)
+	raw := append([]interface{}(nil), a.raw...)
+	T := e.snap.ftype
+	for _, i := range a.templ {
+		v, err := e.placeholderValue(raw[i].(Placeholder), c, a)
+		if err != nil {
+			return nil, nil, &ArgumentError{Index: i, Err: err}
+		}
+		raw[i] = v
+		if T == nil {
+			vals[i] = reflect.ValueOf(v)
+			continue
+		}
+		if vals[i], err = convertArg(paramType(T, i), v); err != nil {
+			return nil, nil, &ArgumentError{Index: i, Err: err}
+		}
+	}
+	return vals, raw, nil
+}
@@ -0,0 +1,66 @@
+package chain_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestPlaceholders(t *testing.T) {
+	type NodeFunc func(string, uint64, int) error
+	got := make(map[string]int)
+	var ids []uint64
+	record := func(name string, id uint64, n int) error {
+		got[name] = n
+		ids = append(ids, id)
+		return nil
+	}
+	c := chain.NewTyped(NodeFunc(nil), chain.WithWorkers(1),
+		chain.WithBoundArgs(chain.NodeName, chain.RunID))
+	p, err := c.Register(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.SetName("first"); err != nil {
+		t.Fatal(err)
+	}
+	if p, err = p.After(record); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.SetName("second"); err != nil {
+		t.Fatal(err)
+	}
+	e := c.Start(7)
+	if err = e.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if got["first"] != 7 || got["second"] != 7 {
+		t.Errorf("funcs were passed %v", got)
+	}
+	if len(ids) != 2 || ids[0] != e.Info().ID || ids[1] != ids[0] {
+		t.Errorf("funcs were passed run IDs %v", ids)
+	}
+}
+
+func TestPlaceholderErrors(t *testing.T) {
+	var got []interface{}
+	c := chain.New()
+	c.Register(func(a, b interface{}) { got = append(got, a, b) })
+	if err := c.Run(chain.Arg(1), "b"); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "b" || got[1] != "b" {
+		t.Errorf("func was passed %v", got)
+	}
+
+	var ae *chain.ArgumentError
+	if err := c.Run(chain.Arg(2), "b"); !errors.As(err, &ae) || ae.Index != 0 {
+		t.Errorf("out of range Arg() returned %v", err)
+	}
+	typed := chain.NewTyped(func(int) {})
+	typed.Register(func(int) {})
+	if err := typed.Run(chain.NodeName); !errors.As(err, &ae) {
+		t.Errorf("mistyped placeholder returned %v", err)
+	}
+}
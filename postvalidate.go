@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"reflect"
+)
+
+// Result describes what became of a single func dispatched by a run.
+// Returned is false if the func was skipped or panicked, otherwise Values
+// holds its results, apart from a trailing error result which is Err.
+type Result struct {
+	Func     FuncInfo
+	Returned bool
+	Values   []interface{}
+	Err      error
+}
+
+func (cn *chainNode) SetPostValidator(fn func([]Result) error) error {
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	if err := cn.checkSealed(); err != nil {
+		return err
+	}
+	cn.opts.postValidator = fn
+	cn.opts.cache.invalidate()
+	return nil
+}
+
+// calls the post validator with the results of every dispatched func in
+// execution order, once every worker has exited
+func (e *Execution) postValidate() {
+	fn := e.snap.opts.postValidator
+	if fn == nil || e.err != nil || e.abandoned {
+		return
+	}
+	var results []Result
+	for _, p := range e.plan {
+		for i, c := range p.calls {
+			r := Result{Func: c.info(p.node)}
+			if out := p.results[i]; out != nil {
+				r.Returned = true
+				if l := len(out); l > 0 && out[l-1].Type() == errorType {
+					r.Err, _ = out[l-1].Interface().(error)
+					out = out[:l-1]
+				}
+				r.Values = make([]interface{}, len(out))
+				for j, v := range out {
+					r.Values[j] = v.Interface()
+				}
+			}
+			results = append(results, r)
+		}
+	}
+	if err := fn(results); err != nil {
+		e.lock.Lock()
+		e.errors = append(e.errors, err)
+		e.lock.Unlock()
+	}
+}
+
+// marks a call that returned without results as having returned
+var noResults = []reflect.Value{}
@@ -0,0 +1,31 @@
+package chain_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestPostValidator(t *testing.T) {
+	notReady := errors.New("not every subsystem is ready")
+	c := chain.NewTyped(func() (bool, error) { return false, nil })
+	c.Register(func() (bool, error) { return true, nil })
+	c.Head().After(func() (bool, error) { return false, nil })
+	var got []chain.Result
+	c.SetPostValidator(func(results []chain.Result) error {
+		got = results
+		for _, r := range results {
+			if !r.Returned || !r.Values[0].(bool) {
+				return notReady
+			}
+		}
+		return nil
+	})
+	if err := c.Run(); err != notReady {
+		t.Fatalf("expected post validation error, got %v", err)
+	}
+	if len(got) != 2 || got[0].Values[0] != true || got[1].Func.Node != 1 {
+		t.Fatalf("unexpected results %+v", got)
+	}
+}
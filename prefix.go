@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"reflect"
+)
+
+// WithPrefixFuncs allows funcs registered with a typed chain to take
+// only a prefix of the chain's parameters, for example func(*testing.T)
+// on a chain of func(*testing.T, ...string). Such funcs are simply not
+// passed the remaining arguments. Their results must still match the
+// chain's exactly.
+func WithPrefixFuncs() Option {
+	return func(o *options) {
+		o.prefixFuncs = true
+	}
+}
+
+// prefixProxy calls a func with only as many arguments as it takes
+type prefixProxy struct {
+	fn reflect.Value
+}
+
+// reports whether a func of type T takes a strict prefix of the
+// parameters of ftype and returns the same results
+func isPrefix(T, ftype reflect.Type) bool {
+	if T.IsVariadic() || T.NumIn() >= ftype.NumIn() || T.NumOut() != ftype.NumOut() {
+		return false
+	}
+	for i := 0; i < T.NumIn(); i++ {
+		if T.In(i) != ftype.In(i) {
+			return false
+		}
+	}
+	for i := 0; i < T.NumOut(); i++ {
+		if T.Out(i) != ftype.Out(i) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p prefixProxy) Call(in []reflect.Value) []reflect.Value {
+	if n := p.fn.Type().NumIn(); len(in) > n {
+		in = in[:n]
+	}
+	return p.fn.Call(in)
+}
+
+func (p prefixProxy) Unwrap() interface{} {
+	return p.fn.Interface()
+}
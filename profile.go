@@ -0,0 +1,52 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"context"
+	"runtime/pprof"
+	"strconv"
+)
+
+// WithProfileLabels makes every run attach pprof labels to the goroutine
+// calling each func for as long as the func runs, so that CPU and
+// goroutine profiles attribute time to individual chain members. The
+// labels are "chain" (name, omitted if empty), "node" (the node's name or,
+// if it has none, its position) and "func" (the func's symbol).
+func WithProfileLabels(name string) Option {
+	return func(o *options) {
+		o.profile = true
+		o.profileName = name
+	}
+}
+
+// invokes c with the profile labels describing it
+func (e *Execution) profiled(c call) {
+	s := e.snap
+	node := s.nodes[c.node].name
+	if node == "" {
+		node = strconv.Itoa(c.node)
+	}
+	labels := []string{"node", node, "func", funcName(c.fn)}
+	if s.opts.profileName != "" {
+		labels = append(labels, "chain", s.opts.profileName)
+	}
+	ctx := e.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	pprof.Do(ctx, pprof.Labels(labels...), func(context.Context) {
+		s.invoke(e, c, e.argsFor(c).vals)
+	})
+}
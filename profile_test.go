@@ -0,0 +1,29 @@
+package chain_test
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"strings"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestProfileLabels(t *testing.T) {
+	var buf bytes.Buffer
+	c := chain.New(chain.WithProfileLabels("startup"))
+	p, _ := c.Register(func() {
+		pprof.Lookup("goroutine").WriteTo(&buf, 1)
+	})
+	if err := p.SetName("db"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range []string{`"chain":"startup"`, `"node":"db"`, `"func":"github.com/jsipprell/go-chain_test.TestProfileLabels.func1"`} {
+		if !strings.Contains(buf.String(), l) {
+			t.Errorf("goroutine profile has no %s label", l)
+		}
+	}
+}
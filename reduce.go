@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+func (cn *chainNode) RunReduce(reduce func(acc, result interface{}) interface{},
+	args ...interface{}) (interface{}, error) {
+	return cn.Snapshot().RunReduce(reduce, args...)
+}
+
+// RunReduce runs the snapshot and then folds the results of every func
+// that was called into a single value, starting from nil. Funcs are folded
+// in execution order, node by node, and in registration order within a
+// node regardless of which finished first. A func's result is its single
+// return value or, if it has more than one, a []interface{} of them; a
+// trailing error result is left out (it is reported by the returned error
+// as usual) and funcs with no other results aren't folded at all.
+func (s *Snapshot) RunReduce(reduce func(acc, result interface{}) interface{},
+	args ...interface{}) (interface{}, error) {
+	e := s.start(&Execution{reducing: true}, nil, args)
+	err := s.opts.handle(e.Err())
+
+	var acc interface{}
+	for _, p := range e.plan {
+		for _, out := range p.results {
+			if l := len(out); l > 0 && out[l-1].Type() == errorType {
+				out = out[:l-1]
+			}
+			switch len(out) {
+			case 0:
+				continue
+			case 1:
+				acc = reduce(acc, out[0].Interface())
+			default:
+				vals := make([]interface{}, len(out))
+				for i, v := range out {
+					vals[i] = v.Interface()
+				}
+				acc = reduce(acc, vals)
+			}
+		}
+	}
+	return acc, err
+}
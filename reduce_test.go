@@ -0,0 +1,54 @@
+package chain_test
+
+import (
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+type VerdictFunc func(int) (bool, error)
+
+func TestRunReduce(t *testing.T) {
+	c := chain.NewTyped(VerdictFunc(nil))
+	pred, err := c.Register(
+		func(i int) (bool, error) { return i > 0, nil },
+		func(i int) (bool, error) { return i < 10, nil },
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pred.After(func(i int) (bool, error) { return i%2 == 0, nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	and := func(acc, result interface{}) interface{} {
+		if acc == nil {
+			return result
+		}
+		return acc.(bool) && result.(bool)
+	}
+	for arg, want := range map[int]bool{4: true, 5: false, 12: false} {
+		got, err := c.RunReduce(and, arg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%d: expected %v, got %v", arg, want, got)
+		}
+	}
+
+	var order []interface{}
+	seq := chain.New()
+	if _, err = seq.Head().RegisterSeq(func() int { return 1 }, func() (int, string) { return 2, "two" }, func() {}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = seq.RunReduce(func(acc, result interface{}) interface{} {
+		order = append(order, result)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 2 || order[0] != 1 || order[1].([]interface{})[1] != "two" {
+		t.Fatalf("unexpected results %v", order)
+	}
+}
@@ -0,0 +1,251 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// RegisterOption modifies how funcs are registered. RegisterOptions can be
+// passed to Register() and friends anywhere among the funcs and apply to
+// every func registered by that call. They are never seen by validators.
+type RegisterOption func(*registration)
+
+// registration holds the RegisterOptions passed with a set of funcs
+type registration struct {
+	key      string
+	done     func(string) (bool, error)
+	expiry   *expiry
+	order    *int
+	handle   *Handle
+	recovery *RecoveryPolicy
+	skipIf   func([]interface{}) bool
+	adapt    bool
+}
+
+// applies the per-func settings of a registration to one of its funcs
+func (r *registration) apply(cn *chainNode, e *entry) {
+	if r == nil {
+		return
+	}
+	if r.handle != nil && r.handle.e == nil {
+		*r.handle = Handle{chain: cn, e: e}
+	}
+	e.key = r.key
+	e.done = r.done
+	e.expiry = r.expiry.clone()
+	e.recovery = r.recovery
+	e.skipIf = r.skipIf
+}
+
+// separates RegisterOptions from the funcs they were passed with. The
+// registration is nil if there were none.
+func splitOptions(fn []interface{}) ([]interface{}, *registration) {
+	var reg *registration
+	var funcs []interface{}
+	for i, f := range fn {
+		opt, ok := f.(RegisterOption)
+		if !ok {
+			if funcs != nil {
+				funcs = append(funcs, f)
+			}
+			continue
+		}
+		if funcs == nil {
+			funcs = append(make([]interface{}, 0, len(fn)-1), fn[:i]...)
+		}
+		if reg == nil {
+			reg = &registration{}
+		}
+		if opt != nil {
+			opt(reg)
+		}
+	}
+	if funcs == nil {
+		return fn, nil
+	}
+	return funcs, reg
+}
+
+// Idempotent gives funcs an idempotency key. Before each call done is
+// asked whether the work identified by key has already been completed
+// (typically by consulting some external system) and if so the func is
+// not called, although its node still waits for the check as it would
+// for the func. An error from done is treated as a failure of the func.
+func Idempotent(key string, done func(key string) (bool, error)) RegisterOption {
+	return func(r *registration) {
+		r.key = key
+		r.done = done
+	}
+}
+
+// SkipIf makes each run ask pred, before anything runs, whether funcs
+// should be skipped given the run's arguments. Skipped funcs are counted
+// as such (see NodeStats) and reported as not having returned (see
+// Result), their node carrying on without them.
+func SkipIf(pred func(args []interface{}) bool) RegisterOption {
+	return func(r *registration) {
+		r.skipIf = pred
+	}
+}
+
+// MaxRuns deactivates funcs once they have been called by n runs. Runs in
+// which a func is skipped (by a selector, SkipIf, an earlier failure, its
+// idempotency key or because its node was checkpointed) don't count. After
+// that they are skipped by every run and eventually removed from the chain
+// (see Expires).
+func MaxRuns(n int) RegisterOption {
+	return func(r *registration) {
+		if r.expiry == nil {
+			r.expiry = &expiry{}
+		}
+		r.expiry.max = int64(n)
+	}
+}
+
+// Expires deactivates funcs once the wall clock passes t. Deactivated funcs
+// are skipped by runs and removed from the chain (emitting EventFuncRemoved)
+// the next time a run or snapshot is taken of it.
+func Expires(t time.Time) RegisterOption {
+	return func(r *registration) {
+		if r.expiry == nil {
+			r.expiry = &expiry{}
+		}
+		r.expiry.until = t
+	}
+}
+
+// expiry tracks the lifetime of a func registered with MaxRuns or Expires
+type expiry struct {
+	max   int64
+	until time.Time
+	runs  int64
+}
+
+func (x *expiry) clone() *expiry {
+	if x == nil {
+		return nil
+	}
+	return &expiry{max: x.max, until: x.until, runs: atomic.LoadInt64(&x.runs)}
+}
+
+func (x *expiry) expired(now time.Time) bool {
+	if x == nil {
+		return false
+	}
+	return (x.max > 0 && atomic.LoadInt64(&x.runs) >= x.max) ||
+		(!x.until.IsZero() && !now.Before(x.until))
+}
+
+// claim counts a run calling the func, returning false if the func has
+// expired.
+func (x *expiry) claim(now time.Time) bool {
+	if x == nil {
+		return true
+	}
+	if !x.until.IsZero() && !now.Before(x.until) {
+		return false
+	}
+	if x.max > 0 {
+		return atomic.AddInt64(&x.runs, 1) <= x.max
+	}
+	return true
+}
+
+// removes expired funcs from every node in the chain, must be called with
+// the chain locked.
+func (cn *chainNode) prune() {
+	now := time.Now()
+	for n := cn.getFirst(); n != nil; n = n.getNext() {
+		live := n.funcs[:0]
+		for _, e := range n.funcs {
+			if e.expiry.expired(now) {
+				n.queue(Event{Kind: EventFuncRemoved, Node: n, Func: funcType(e.fn)})
+				cn.opts.arena.free(e)
+				continue
+			}
+			live = append(live, e)
+		}
+		for i := len(live); i < len(n.funcs); i++ {
+			n.funcs[i] = nil
+		}
+		n.funcs = live
+	}
+}
+
+// Standard weights for use with Order(), modelled after Apache's hook
+// ordering. Offsets from them (such as OrderMiddle+1) are perfectly fine.
+const (
+	OrderReallyFirst = -10
+	OrderFirst       = 0
+	OrderMiddle      = 10
+	OrderLast        = 20
+	OrderReallyLast  = 30
+)
+
+// Order places funcs passed to Register() in a node determined by weight
+// rather than by the node Register() was called on. All funcs registered
+// with the same weight share a node and nodes created this way are kept
+// sorted by weight, lowest first, so independent packages can agree on an
+// ordering without access to each other's Predicates. A new weighted node
+// is inserted just before the first heavier one, otherwise after the last
+// weighted node or at the end of the chain. Order cannot be combined with
+// Before(), After() and friends, which return ErrOrderConflict.
+func Order(weight int) RegisterOption {
+	return func(r *registration) {
+		r.order = &weight
+	}
+}
+
+func (r *registration) ordered() bool {
+	return r != nil && r.order != nil
+}
+
+// finds or creates the node holding funcs of the given weight, must be
+// called with the chain locked.
+func (cn *chainNode) ordered(weight int) *chainNode {
+	first := cn.getFirst()
+	// a brand new chain's empty root node is claimed rather than left
+	// in front of every weighted node
+	if first.after == nil && !first.weighted && len(first.funcs) == 0 {
+		first.weighted, first.weight = true, weight
+		return first
+	}
+	var last *chainNode
+	for n := first; n != nil; n = n.getNext() {
+		if !n.weighted {
+			continue
+		}
+		switch {
+		case n.weight == weight:
+			return n
+		case n.weight > weight:
+			n = n.insertBefore()
+			n.weighted, n.weight = true, weight
+			return n
+		}
+		last = n
+	}
+	if last == nil {
+		last = cn.getLast()
+		// nothing goes after the true last, see WithAnchors
+		if last.anchor == anchorLast {
+			last = last.before
+		}
+	}
+	n := last.insertAfter()
+	n.weighted, n.weight = true, weight
+	return n
+}
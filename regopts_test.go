@@ -0,0 +1,185 @@
+package chain_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestIdempotent(t *testing.T) {
+	var lock sync.Mutex
+	completed := map[string]bool{}
+	done := func(key string) (bool, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		return completed[key], nil
+	}
+	work := func(key string) func() {
+		return func() {
+			lock.Lock()
+			defer lock.Unlock()
+			completed[key] = true
+		}
+	}
+
+	c := chain.New()
+	pred, err := c.Register(work("a"), chain.Idempotent("a", done))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ran := 0
+	if _, err = pred.After(func() { ran++ }); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		e := c.Start()
+		if err = e.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if d := e.Stats().Nodes[0].Done; d != i {
+			t.Fatalf("run %d: expected %d funcs already done, got %d", i, i, d)
+		}
+	}
+	if ran != 2 {
+		t.Fatalf("following node ran %d times", ran)
+	}
+}
+
+func TestExpiringRegistrations(t *testing.T) {
+	c := chain.New()
+	var once, expired, always int
+	pred, err := c.Register(func() { once++ }, chain.MaxRuns(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pred.Register(func() { expired++ }, chain.Expires(time.Now().Add(-time.Second))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pred.After(func() { always++ }); err != nil {
+		t.Fatal(err)
+	}
+	var removed []chain.Event
+	c.OnChange(func(ev chain.Event) {
+		if ev.Kind == chain.EventFuncRemoved {
+			removed = append(removed, ev)
+		}
+	})
+
+	for i := 0; i < 3; i++ {
+		if err = c.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if once != 1 || expired != 0 || always != 3 {
+		t.Fatalf("unexpected call counts: once=%d expired=%d always=%d", once, expired, always)
+	}
+	if len(removed) != 2 || removed[0].Node != pred {
+		t.Fatalf("expected both expired funcs to be removed, got %v", removed)
+	}
+	if l := c.Snapshot().Len(); l != 1 {
+		t.Fatalf("expected 1 remaining func, got %d", l)
+	}
+}
+
+func TestOrder(t *testing.T) {
+	c := chain.New(chain.WithWorkers(1))
+	var got []string
+	add := func(name string, weight int) chain.Predicate {
+		p, err := c.Register(func() { got = append(got, name) }, chain.Order(weight))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	middle := add("middle", chain.OrderMiddle)
+	add("last", chain.OrderLast)
+	add("first", chain.OrderFirst)
+	add("middle+1", chain.OrderMiddle+1)
+	if p := add("middle again", chain.OrderMiddle); p != middle {
+		t.Fatal("funcs of equal weight should share a node")
+	}
+
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"first", "middle", "middle again", "middle+1", "last"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+
+	if _, err := middle.After(func() {}, chain.Order(1)); !errors.Is(err, chain.ErrOrderConflict) {
+		t.Fatalf("expected %v, got %v", chain.ErrOrderConflict, err)
+	}
+}
+
+func TestSkipIf(t *testing.T) {
+	var lock sync.Mutex
+	var ran []string
+	record := func(name string) func(bool) {
+		return func(bool) {
+			lock.Lock()
+			defer lock.Unlock()
+			ran = append(ran, name)
+		}
+	}
+	quiet := chain.SkipIf(func(args []interface{}) bool { return args[0].(bool) })
+	c := chain.New()
+	p, err := c.Register(record("always"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.Register(record("noisy"), quiet); err != nil {
+		t.Fatal(err)
+	}
+	e := c.Start(true)
+	if err = e.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 1 || ran[0] != "always" {
+		t.Errorf("ran %v", ran)
+	}
+	if s := e.Stats().Nodes[0]; s.Funcs != 2 || s.Skipped != 1 {
+		t.Errorf("node dispatched %d funcs and skipped %d", s.Funcs, s.Skipped)
+	}
+	ran = nil
+	if err = c.Run(false); err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 2 {
+		t.Errorf("ran %v", ran)
+	}
+}
+
+func TestMaxRunsSkipped(t *testing.T) {
+	c := chain.New()
+	skip := true
+	var calls int
+	if _, err := c.Register(func() { calls++ }, chain.MaxRuns(1),
+		chain.SkipIf(func([]interface{}) bool { return skip })); err != nil {
+		t.Fatal(err)
+	}
+	// runs skipping the func don't use up its only run
+	for i := 0; i < 2; i++ {
+		if err := c.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	skip = false
+	for i := 0; i < 2; i++ {
+		if err := c.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("func called %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,757 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Execution is the handle to a single run of a call chain as returned by
+// Start() or StartFiltered(). All of the synchronization state used during
+// a run belongs to its Execution, so a chain can be run again (or
+// concurrently) without runs interfering with each other or with the
+// node waiters used by Iterate().
+//
+// Funcs are executed by a small pool of worker goroutines belonging to the
+// run (see WithWorkers), and each node is released by the completion of
+// the last func in the node before it.
+type Execution struct {
+	done  chan struct{}
+	lock  sync.Mutex
+	stats Stats
+
+	errors []error
+	err    error
+
+	snap    *Snapshot
+	info    *RunInfo
+	ctx     context.Context
+	args    *argSet
+	plan    []*phase
+	next    int
+	work    chan call
+	workers sync.WaitGroup
+
+	stepping bool
+	selector func(FuncInfo, []interface{}) Decision
+	fan      *ArgSets
+	fanSets  map[interface{}]*argSet
+	resuming bool
+	reducing bool
+
+	// set once a node's error handler decides on StopChain, or by Cancel()
+	aborted int32
+	// set by Cancel()
+	cancelRequested int32
+	// set once a func is skipped because the run's context is done
+	canceled bool
+
+	// closed by stop(), along with the context passed to funcs
+	halt      sync.Once
+	halted    chan struct{}
+	cancelCtx context.CancelFunc
+	// set once the grace period has run out, anything still running
+	// is ignored
+	abandoned bool
+
+	// only set for drain runs (see WithDrain), planDone is closed along
+	// with the work queue
+	deadline time.Time
+	planDone chan struct{}
+	queueing sync.WaitGroup
+	drained  []FuncInfo
+	hung     int
+
+	state int32
+	seed  int64
+}
+
+// phase is a node which has at least one func to run.
+type phase struct {
+	index     int
+	node      int
+	calls     []*entry
+	remaining int32
+	finished  chan struct{}
+
+	// set once the node's error handler decides on StopNode
+	stopped int32
+
+	// only allocated when the run's results are being reduced or
+	// validated, each element is written by the worker that ran the
+	// corresponding call
+	results [][]reflect.Value
+
+	// only allocated for context runs of chains with WithOutput
+	output []*outputBuffer
+
+	// the context passed to the phase's funcs by context runs
+	nodeCtx context.Context
+
+	// only allocated once a call is to be skipped, by the run's selector
+	// (see RunSelect) or its own SkipIf
+	skipped []bool
+
+	// only allocated for chaos runs with delays, see WithChaos
+	delays []time.Duration
+
+	// only allocated for fan-out runs (see RunFanOut), the arguments of
+	// each call
+	sets []*argSet
+
+	// named nodes without any funcs between this phase and the next,
+	// marked completed once the run gets past this phase
+	passes []int
+
+	// only allocated for drain runs (see WithDrain), the state of each
+	// call; the budget and its timer are protected by the run lock
+	states []int32
+	budget time.Duration
+	timer  *time.Timer
+}
+
+// StepResult reports which funcs ran as the result of a single call to
+// Execution.Step(). Node is the position of the released node within the
+// run.
+type StepResult struct {
+	Node  int
+	Funcs []interface{}
+}
+
+// Stats records what a single chain run did and how long it took. Nodes
+// are listed in execution order, including those that had no funcs
+// dispatched.
+type Stats struct {
+	Run   *RunInfo
+	Start time.Time
+	End   time.Time
+	Nodes []NodeStats
+}
+
+// NodeStats is the per-node portion of Stats. Start and End bracket the
+// first func starting and the last func finishing; both are zero if the
+// node dispatched no funcs. Errors collects any non-nil error returned as
+// the final result of a func. Skipped counts dispatched funcs that were not
+// called because of an earlier failure (see WithStopOnError and
+// Predicate.OnError), a selector (see RunSelect) or SkipIf, Done those not
+// called because their idempotency key reported the work was already done
+// (see Idempotent).
+type NodeStats struct {
+	Start           time.Time
+	End             time.Time
+	Funcs           int
+	Skipped         int
+	Done            int
+	Slowest         interface{}
+	SlowestDuration time.Duration
+	Errors          []error
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// ErrGoexit is recorded in place of a func that called runtime.Goexit()
+// (for example via t.Fatal()) during a run.
+var ErrGoexit = errors.New("func called runtime.Goexit")
+
+// PanicError is recorded in place of a func that panicked during a run.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", p.Value)
+}
+
+// ErrorList is returned by Execution.Err() (and Run()) when more than one
+// func fails.
+type ErrorList []error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, err := range l {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d errors: %s", len(l), strings.Join(msgs, "; "))
+}
+
+func (l ErrorList) Unwrap() []error {
+	return l
+}
+
+// Wait blocks until every func dispatched by the run has returned.
+func (e *Execution) Wait() {
+	<-e.done
+}
+
+// Info returns the run's metadata. Unlike most Execution methods it doesn't
+// wait for the run to finish.
+func (e *Execution) Info() *RunInfo {
+	return e.info
+}
+
+// Stats waits for the run to finish and then returns its statistics.
+func (e *Execution) Stats() *Stats {
+	e.Wait()
+	return &e.stats
+}
+
+// Step releases the next node of a run started with StartStepped(), waits
+// for all of its funcs to return and reports what ran. Nodes which have
+// nothing to run are skipped over. Returns false once every node has been
+// released, or if the run wasn't started in stepped mode. A stepped run
+// that is abandoned before it finishes leaves its workers blocked.
+func (e *Execution) Step() (*StepResult, bool) {
+	e.lock.Lock()
+	if !e.stepping || e.next >= len(e.plan) {
+		e.lock.Unlock()
+		return nil, false
+	}
+	p := e.plan[e.next]
+	e.next++
+	e.lock.Unlock()
+	if e.State() == Idle {
+		e.setState(Running)
+	}
+
+	e.release(p)
+	<-p.finished
+	res := &StepResult{Node: p.node}
+	for _, c := range p.calls {
+		res.Funcs = append(res.Funcs, c.id)
+	}
+	return res, true
+}
+
+// returns the error a func returned as its final result, if any
+func resultErr(out []reflect.Value) (err error) {
+	if l := len(out); l > 0 && out[l-1].IsValid() && out[l-1].Type() == errorType {
+		err, _ = out[l-1].Interface().(error)
+	}
+	return
+}
+
+func (e *Execution) record(c call, start, end time.Time, err error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if !e.live(c) {
+		return
+	}
+
+	fn := c.id
+	ns := &e.stats.Nodes[c.node]
+	if ns.Start.IsZero() || start.Before(ns.Start) {
+		ns.Start = start
+	}
+	if end.After(ns.End) {
+		ns.End = end
+	}
+	if d := end.Sub(start); ns.Slowest == nil || d > ns.SlowestDuration {
+		ns.Slowest = fn
+		ns.SlowestDuration = d
+	}
+	if err != nil {
+		ns.Errors = append(ns.Errors, err)
+		e.errors = append(e.errors, err)
+	}
+}
+
+func (e *Execution) fail(c call, err error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if !e.live(c) {
+		return
+	}
+	e.stats.Nodes[c.node].Errors = append(e.stats.Nodes[c.node].Errors, err)
+	e.errors = append(e.errors, err)
+}
+
+func (e *Execution) skip(c call) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if !e.live(c) {
+		return
+	}
+	e.stats.Nodes[c.node].Skipped++
+}
+
+// records a func skipped because the run's context is done
+func (e *Execution) cancel(c call, err error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if !e.live(c) {
+		return
+	}
+	e.stats.Nodes[c.node].Skipped++
+	e.canceled = true
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+func (e *Execution) failed() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return len(e.errors) > 0
+}
+
+// Err waits for the run to finish and returns the error that prevented it
+// from running at all (or, for RunContext, from running to completion), if
+// any. Otherwise, if any funcs failed, their errors
+// are returned (as an ErrorList if there was more than one).
+func (e *Execution) Err() error {
+	e.Wait()
+	if e.err != nil {
+		return e.err
+	}
+	return combineErrors(e.errors)
+}
+
+// returns nil, the only error or an ErrorList
+func combineErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return ErrorList(errs)
+}
+
+// Errors waits for the run to finish and returns every non-nil error
+// returned by a func (as its final result), or recovered from a func that
+// panicked, in the order they occurred.
+func (e *Execution) Errors() []error {
+	e.Wait()
+	return e.errors
+}
+
+func (cn *chainNode) Start(args ...interface{}) *Execution {
+	return cn.Snapshot().Start(args...)
+}
+
+func (cn *chainNode) StartFiltered(filter func(interface{}, []interface{}) bool,
+	args ...interface{}) *Execution {
+	return cn.Snapshot().StartFiltered(filter, args...)
+}
+
+func (cn *chainNode) StartStepped(args ...interface{}) *Execution {
+	return cn.Snapshot().StartStepped(args...)
+}
+
+// StartFiltered is the asynchronous form of RunFiltered.
+func (s *Snapshot) StartFiltered(filter func(interface{}, []interface{}) bool,
+	args ...interface{}) *Execution {
+	return s.start(&Execution{}, filter, args)
+}
+
+// StartStepped starts a run in which no node is released until Step() is
+// called on the returned Execution.
+func (s *Snapshot) StartStepped(args ...interface{}) *Execution {
+	return s.start(&Execution{stepping: true}, nil, args)
+}
+
+func (s *Snapshot) start(e *Execution, filter func(interface{}, []interface{}) bool,
+	args []interface{}) *Execution {
+	e.done = make(chan struct{})
+	e.halted = make(chan struct{})
+	e.snap = s
+	if !e.stepping {
+		e.setState(Running)
+	}
+	e.info = newRunInfo(e.ctx)
+	e.stats.Run = e.info
+	e.started()
+	if e.ctx != nil {
+		e.ctx, e.cancelCtx = context.WithCancel(context.WithValue(e.ctx, runInfoKey{}, e.info))
+	}
+	if e.fan == nil {
+		if e.args, e.err = e.prepare(args); e.err != nil {
+			go e.finish()
+			return e
+		}
+	}
+
+	e.stats.Start = e.info.Start
+	e.stats.Nodes = make([]NodeStats, len(s.nodes))
+	widest := 0
+	now := e.stats.Start
+	if d := s.opts.drain; d > 0 {
+		e.deadline = now.Add(d)
+		e.planDone = make(chan struct{})
+	}
+	rng, chaos := e.shufflers()
+	var sel selection
+	var leading, empty []int
+	for node, n := range s.nodes {
+		p := &phase{index: len(e.plan), node: node, finished: make(chan struct{})}
+		sel.node = false
+		if len(n.funcs) == 0 && n.name != "" {
+			empty = append(empty, node)
+		}
+		for i, ent := range n.funcs {
+			a := e.args
+			if e.fan != nil {
+				if a, e.err = e.fanned(node, i, ent); e.err != nil {
+					go e.finish()
+					return e
+				}
+			}
+			if (filter == nil || filter(ent.id, a.args)) && !ent.expiry.expired(now) {
+				skip := e.selector != nil && e.selects(&sel, ent, node, a.args)
+				if !skip && ent.skipIf != nil {
+					skip = ent.skipIf(a.args)
+				}
+				if skip && p.skipped == nil {
+					p.skipped = make([]bool, len(p.calls), len(n.funcs))
+				}
+				p.calls = append(p.calls, ent)
+				if p.skipped != nil {
+					p.skipped = append(p.skipped, skip)
+				}
+				if e.fan != nil {
+					p.sets = append(p.sets, a)
+				}
+			}
+		}
+		e.stats.Nodes[node].Funcs = len(p.calls)
+		if e.resuming && len(p.calls) > 0 {
+			if done, err := s.checkpointed(n); err != nil {
+				e.err = err
+				go e.finish()
+				return e
+			} else if done {
+				// completed by an earlier run
+				e.stats.Nodes[node].Skipped = len(p.calls)
+				p.calls = nil
+				e.signal(node)
+			}
+		}
+		// nodes with nothing to run don't form a barrier at all
+		if len(p.calls) > 0 {
+			if rng != nil {
+				shuffle(rng, p)
+			} else if chaos != nil {
+				e.disorder(chaos, p)
+			}
+			p.remaining = int32(len(p.calls))
+			if e.planDone != nil {
+				p.states = make([]int32, len(p.calls))
+			}
+			if e.reducing || s.opts.postValidator != nil {
+				p.results = make([][]reflect.Value, len(p.calls))
+			}
+			if e.ctx != nil {
+				p.nodeCtx = context.WithValue(e.ctx, localsKey{}, &Locals{})
+				if s.opts.output != nil {
+					p.output = make([]*outputBuffer, len(p.calls))
+				}
+			}
+			if len(e.plan) > 0 {
+				e.plan[len(e.plan)-1].passes = empty
+			} else {
+				leading = empty
+			}
+			empty = nil
+			e.plan = append(e.plan, p)
+			if len(p.calls) > widest {
+				widest = len(p.calls)
+			}
+		}
+	}
+
+	if len(e.plan) > 0 {
+		e.plan[len(e.plan)-1].passes = empty
+	} else {
+		leading = append(leading, empty...)
+	}
+	for _, node := range leading {
+		e.signal(node)
+	}
+
+	workers := s.opts.workers
+	if workers <= 0 || workers > widest {
+		workers = widest
+	}
+	if rng != nil && workers > 1 {
+		workers = 1
+	}
+	// only one node is ever released at a time so the queue never needs
+	// to hold more than the widest node.
+	e.work = make(chan call, widest)
+	e.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go e.worker()
+	}
+	go e.finish()
+
+	if len(e.plan) == 0 {
+		e.closeWork()
+	} else if !e.stepping {
+		e.release(e.plan[0])
+	}
+	return e
+}
+
+// a single func queued for execution by a run
+type call struct {
+	*phase
+	*entry
+	index int
+}
+
+func (e *Execution) worker() {
+	defer e.workers.Done()
+	for c := range e.work {
+		e.call(c)
+	}
+}
+
+// call runs a single func on behalf of a worker. Panics are recovered and
+// recorded as errors. A func which calls runtime.Goexit() (as t.Fatal()
+// does) takes its worker with it, so a replacement is started.
+func (e *Execution) call(c call) {
+	if !c.begin() {
+		return
+	}
+	exited := true
+	defer func() {
+		if exited {
+			if r := recover(); r != nil {
+				err := &PanicError{Value: r, Stack: debug.Stack()}
+				e.fail(c, err)
+				e.snap.opts.trace.add(e.info, TraceFuncEnd, c, time.Time{}, err)
+				e.decide(c, err)
+			} else {
+				e.fail(c, ErrGoexit)
+				e.snap.opts.trace.add(e.info, TraceFuncEnd, c, time.Time{}, ErrGoexit)
+				e.decide(c, ErrGoexit)
+				e.workers.Add(1)
+				go e.worker()
+			}
+		}
+		if c.end() && atomic.AddInt32(&c.remaining, -1) == 0 {
+			e.completed(c.phase)
+		}
+	}()
+	if e.snap.opts.profile {
+		e.profiled(c)
+	} else {
+		e.snap.invoke(e, c, e.argsFor(c).vals)
+	}
+	exited = false
+}
+
+// queues every func in a phase for the workers, once any nodes in other
+// chains that it waits for have completed
+func (e *Execution) release(p *phase) {
+	e.stopAfterFailure(p)
+	if p.states != nil {
+		e.budget(p)
+	}
+	if deps := e.snap.nodes[p.node].deps; len(deps) > 0 {
+		go func() {
+			e.await(p, deps)
+			e.queue(p)
+		}()
+		return
+	}
+	e.queue(p)
+}
+
+func (e *Execution) queue(p *phase) {
+	if p.states == nil {
+		for i, c := range p.calls {
+			e.work <- call{phase: p, entry: c, index: i}
+		}
+		return
+	}
+	// a drained node's calls are pointless, don't hold up the run with them
+	defer e.queueing.Done()
+	for i, c := range p.calls {
+		select {
+		case e.work <- call{phase: p, entry: c, index: i}:
+		case <-p.finished:
+			return
+		}
+	}
+}
+
+// called by the worker which ran the last func in a phase
+func (e *Execution) completed(p *phase) {
+	if p.states != nil {
+		e.budgeted(p)
+	}
+	e.checkpoint(p)
+	if p.output != nil {
+		e.flushOutput(p)
+	}
+	if e.clean(p) {
+		e.signal(p.node)
+	}
+	if len(p.passes) > 0 && e.carriesOn() {
+		for _, node := range p.passes {
+			e.signal(node)
+		}
+	}
+	next := p.index + 1
+	close(p.finished)
+	switch {
+	case next >= len(e.plan):
+		e.closeWork()
+	case e.stepping:
+		// Step() releases the next phase
+	case e.snap.opts.nodeDelay > 0:
+		time.AfterFunc(e.snap.opts.nodeDelay, func() {
+			e.release(e.plan[next])
+		})
+	default:
+		e.release(e.plan[next])
+	}
+}
+
+func (e *Execution) finish() {
+	defer close(e.done)
+	e.awaitWorkers()
+	if e.cancelCtx != nil {
+		e.cancelCtx()
+	}
+	e.stats.End = time.Now()
+	e.postValidate()
+	e.settle()
+	e.finished()
+	if cb := e.snap.opts.statsCallback; cb != nil {
+		cb(&e.stats)
+	}
+}
+
+// invoke calls a single func once the node it belongs to has been
+// released.
+func (s *Snapshot) invoke(e *Execution, c call, in []reflect.Value) {
+	e.delay(c)
+	if e.stopped(c) && atomic.LoadInt32(&e.cancelRequested) != 0 {
+		e.cancel(c, context.Canceled)
+		s.opts.trace.add(e.info, TraceFuncSkipped, c, time.Time{}, context.Canceled)
+		return
+	}
+	if e.stopped(c) || (c.skipped != nil && c.skipped[c.index]) {
+		e.skip(c)
+		s.opts.trace.add(e.info, TraceFuncSkipped, c, time.Time{}, nil)
+		return
+	}
+	raw := e.argsFor(c).raw
+	if a := e.argsFor(c); a.templ != nil {
+		var err error
+		if in, raw, err = e.substitute(c, a, in); err != nil {
+			e.fail(c, err)
+			s.opts.trace.add(e.info, TraceFuncSkipped, c, time.Time{}, err)
+			e.decide(c, err)
+			return
+		}
+	}
+	if e.ctx != nil {
+		if err := e.ctx.Err(); err != nil {
+			e.cancel(c, err)
+			s.opts.trace.add(e.info, TraceFuncSkipped, c, time.Time{}, err)
+			return
+		}
+		if c.ctx {
+			in = e.withContext(c, in)
+		}
+	}
+	if c.done != nil {
+		done, err := c.done(c.key)
+		switch {
+		case err != nil:
+			e.fail(c, err)
+			s.opts.trace.add(e.info, TraceFuncSkipped, c, time.Time{}, err)
+			e.decide(c, err)
+			return
+		case done:
+			e.lock.Lock()
+			if e.live(c) {
+				e.stats.Nodes[c.node].Done++
+			}
+			e.lock.Unlock()
+			s.opts.trace.add(e.info, TraceFuncSkipped, c, time.Time{}, nil)
+			return
+		}
+	}
+	start := time.Now()
+	// only funcs actually called use up one of their runs, one whose last
+	// run went to a concurrent run since this one was planned is skipped
+	if !c.expiry.claim(start) {
+		e.skip(c)
+		s.opts.trace.add(e.info, TraceFuncSkipped, c, time.Time{}, nil)
+		return
+	}
+	s.opts.trace.add(e.info, TraceFuncStart, c, time.Time{}, nil)
+	if wd := s.opts.watchdog; wd != nil {
+		fired := make(chan struct{})
+		timer := time.AfterFunc(s.opts.watchdogThreshold, func() {
+			defer close(fired)
+			wd(c.id, time.Since(start))
+		})
+		// don't let the run finish out from underneath a report
+		defer func() {
+			if !timer.Stop() {
+				<-fired
+			}
+		}()
+	}
+	if s.ftype == nil && s.opts.argPolicy != ArgsStrict {
+		if T := funcType(c.fn); T.Kind() == reflect.Func {
+			in = s.opts.argPolicy.fitValues(T, in)
+		}
+	}
+	var out []reflect.Value
+	var err error
+	switch {
+	case c.direct != nil && len(in) == 0:
+		c.direct()
+	case c.run != nil && s.ftype == nil:
+		// Runners on untyped chains are passed the run's arguments as is
+		err = c.run(raw)
+	default:
+		out = c.fn.Call(in)
+		err = resultErr(out)
+	}
+	e.record(c, start, time.Now(), err)
+	s.opts.trace.add(e.info, TraceFuncEnd, c, start, err)
+	if err != nil {
+		e.decide(c, err)
+	}
+	if c.results != nil {
+		e.lock.Lock()
+		if out == nil {
+			out = noResults
+			// from a Runner called directly
+			if err != nil {
+				out = []reflect.Value{reflect.ValueOf(&err).Elem()}
+			}
+		}
+		if e.live(c) {
+			c.results[c.index] = out
+		}
+		e.lock.Unlock()
+	}
+}
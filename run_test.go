@@ -0,0 +1,383 @@
+package chain_test
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestStats(t *testing.T) {
+	var cbStats *chain.Stats
+	c := chain.New(chain.WithStatsCallback(func(s *chain.Stats) {
+		cbStats = s
+	}))
+	failure := errors.New("failure")
+	pred, err := c.Register(func() error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pred.Register(func() error {
+		time.Sleep(10 * time.Millisecond)
+		return failure
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pred.After(func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := c.Start().Stats()
+	if stats != cbStats {
+		t.Fatal("stats callback was not handed the run's statistics")
+	}
+	if len(stats.Nodes) != 2 {
+		t.Fatalf("expected 2 nodes, got %d", len(stats.Nodes))
+	}
+	first, second := stats.Nodes[0], stats.Nodes[1]
+	if first.Funcs != 2 || second.Funcs != 1 {
+		t.Fatalf("wrong func counts: %d, %d", first.Funcs, second.Funcs)
+	}
+	if first.SlowestDuration < 10*time.Millisecond {
+		t.Fatalf("slowest func only took %v", first.SlowestDuration)
+	}
+	if len(first.Errors) != 1 || first.Errors[0] != failure {
+		t.Fatalf("expected failure to be recorded, got %v", first.Errors)
+	}
+	if second.Start.Before(first.End) {
+		t.Fatal("second node started before first node finished")
+	}
+	if stats.End.Before(second.End) {
+		t.Fatal("run ended before its last node")
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	type watched struct {
+		fn      interface{}
+		elapsed time.Duration
+	}
+	C := make(chan watched, 2)
+	c := chain.New(chain.WithWatchdog(5*time.Millisecond, func(fn interface{}, elapsed time.Duration) {
+		C <- watched{fn, elapsed}
+	}))
+	slow := func() { time.Sleep(50 * time.Millisecond) }
+	if _, err := c.Register(slow); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Register(func() {}); err != nil {
+		t.Fatal(err)
+	}
+	c.Run()
+	close(C)
+
+	var got []watched
+	for w := range C {
+		got = append(got, w)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected exactly one watchdog report, got %d", len(got))
+	}
+	if got[0].elapsed < 5*time.Millisecond {
+		t.Fatalf("watchdog fired after only %v", got[0].elapsed)
+	}
+	if _, ok := got[0].fn.(func()); !ok {
+		t.Fatalf("watchdog reported %T instead of the slow func", got[0].fn)
+	}
+}
+
+func TestOverlappingRuns(t *testing.T) {
+	release := make(chan struct{})
+	var lock sync.Mutex
+	var order []string
+	record := func(s string) {
+		lock.Lock()
+		defer lock.Unlock()
+		order = append(order, s)
+	}
+
+	c := chain.New()
+	pred, err := c.Register(func(s string) {
+		<-release
+		record("first " + s)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pred.After(func(s string) { record("second " + s) }); err != nil {
+		t.Fatal(err)
+	}
+
+	// neither run can finish until both have been started
+	e1 := c.Start("a")
+	e2 := c.Start("b")
+	close(release)
+	e1.Wait()
+	e2.Wait()
+
+	if len(order) != 4 {
+		t.Fatalf("expected 4 calls, got %v", order)
+	}
+	pos := make(map[string]int)
+	for i, s := range order {
+		pos[s] = i
+	}
+	for _, run := range []string{"a", "b"} {
+		if pos["first "+run] > pos["second "+run] {
+			t.Fatalf("run %s ran out of order: %v", run, order)
+		}
+	}
+}
+
+func TestStepped(t *testing.T) {
+	var calls int32
+	inc := func() { atomic.AddInt32(&calls, 1) }
+
+	c := chain.New()
+	p, _ := c.Register(inc)
+	p.Register(inc)
+	// empty nodes are skipped over
+	p, _ = p.After()
+	p.After(inc)
+
+	e := c.StartStepped()
+	time.Sleep(5 * time.Millisecond)
+	if n := atomic.LoadInt32(&calls); n != 0 {
+		t.Fatalf("%d funcs ran before the first step", n)
+	}
+	res, ok := e.Step()
+	if !ok || res.Node != 0 || len(res.Funcs) != 2 || atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("first step did not run the first node: %v", res)
+	}
+	res, ok = e.Step()
+	if !ok || res.Node != 2 || len(res.Funcs) != 1 || atomic.LoadInt32(&calls) != 3 {
+		t.Fatalf("second step did not run the last node: %v", res)
+	}
+	if _, ok = e.Step(); ok {
+		t.Fatal("stepped past the end of the chain")
+	}
+	e.Wait()
+}
+
+type ErrorFunc func(int) error
+
+func TestErrorFuncs(t *testing.T) {
+	failure := errors.New("failure")
+	build := func(opts ...chain.Option) (chain.Root, *int32) {
+		var last int32
+		c := chain.NewTyped(ErrorFunc(nil), opts...)
+		p, err := c.Register(func(int) error { return nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p, err = p.After(func(int) error { return failure }); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = p.After(func(int) error {
+			atomic.AddInt32(&last, 1)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return c, &last
+	}
+
+	c, last := build()
+	e := c.Start(1)
+	if errs := e.Errors(); len(errs) != 1 || errs[0] != failure {
+		t.Fatalf("expected failure to be collected, got %v", errs)
+	}
+	if *last != 1 {
+		t.Fatal("last node should still run by default")
+	}
+
+	c, last = build(chain.WithStopOnError())
+	e = c.Start(1)
+	if errs := e.Errors(); len(errs) != 1 || errs[0] != failure {
+		t.Fatalf("expected failure to be collected, got %v", errs)
+	}
+	if *last != 0 {
+		t.Fatal("last node ran despite an earlier failure")
+	}
+	if s := e.Stats().Nodes[2]; s.Skipped != 1 {
+		t.Fatalf("expected 1 skipped func in last node, got %d", s.Skipped)
+	}
+
+	// funcs sharing the failing node still run, even one at a time
+	var siblings int32
+	c = chain.NewTyped(ErrorFunc(nil), chain.WithStopOnError(), chain.WithWorkers(1))
+	p, err := c.Register(func(int) error { return failure })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err = p.Register(func(int) error {
+			atomic.AddInt32(&siblings, 1)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = c.Run(1); err != failure {
+		t.Fatalf("expected %v, got %v", failure, err)
+	}
+	if siblings != 3 {
+		t.Fatalf("%d of 3 funcs sharing the failing node ran", siblings)
+	}
+}
+
+func BenchmarkRun(b *testing.B) {
+	for _, bench := range []struct {
+		name string
+		c    chain.Root
+		fn   interface{}
+		args []interface{}
+	}{
+		{"plain", chain.New(), func() {}, nil},
+		{"typed", chain.NewTyped(ErrorFunc(nil)), func(int) error { return nil }, []interface{}{1}},
+	} {
+		p, _ := bench.c.Register(bench.fn)
+		for i := 0; i < 100; i++ {
+			p.Register(bench.fn)
+			if i%10 == 0 {
+				p, _ = p.After(bench.fn)
+			}
+		}
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				bench.c.Run(bench.args...)
+			}
+		})
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	var running, peak int32
+	fn := func() {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(2 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+	}
+	c := chain.New(chain.WithWorkers(2))
+	for i := 0; i < 6; i++ {
+		c.Register(fn)
+	}
+	c.Run()
+	if peak > 2 {
+		t.Fatalf("%d funcs ran concurrently with only 2 workers", peak)
+	}
+
+	// unbounded workers allow funcs in the same node to rendezvous
+	var ready sync.WaitGroup
+	ready.Add(3)
+	rendezvous := func() {
+		ready.Done()
+		ready.Wait()
+	}
+	c = chain.New(chain.WithWorkers(0))
+	for i := 0; i < 3; i++ {
+		c.Register(rendezvous)
+	}
+	c.Run()
+}
+
+func TestDefaultWorkers(t *testing.T) {
+	// every func of a node wider than any fixed pool must run at once
+	const wide = 20
+	var ready sync.WaitGroup
+	ready.Add(wide)
+	c := chain.New()
+	for i := 0; i < wide; i++ {
+		c.Register(func() {
+			ready.Done()
+			ready.Wait()
+		})
+	}
+	done := make(chan error, 1)
+	go func() { done <- c.Run() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("funcs sharing a node could not rendezvous")
+	}
+}
+
+func TestRunArguments(t *testing.T) {
+	c := chain.NewTyped(TestVariadicFunc(nil))
+	if _, err := c.Register(func(x *testing.T, vals ...string) {
+		t.Fatal("should never run")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]interface{}{
+		{},
+		{"not a *testing.T"},
+		{t, "a", 1},
+	} {
+		if err := c.Start(args...).Err(); err == nil {
+			t.Fatalf("expected arguments %v to be rejected", args)
+		}
+	}
+
+	var handled error
+	h := chain.NewTyped(TestFunc(nil), chain.WithErrorHandler(func(err error) {
+		handled = err
+	}))
+	if err := h.Run(1); err != handled {
+		t.Fatalf("Run returned %v, error handler got %v", err, handled)
+	}
+	var argErr *chain.ArgumentError
+	if !errors.As(handled, &argErr) || argErr.Index != 0 {
+		t.Fatalf("error handler got %v", handled)
+	}
+	if err := c.Run(); err == nil {
+		t.Fatal("expected Run to return an error without an error handler")
+	}
+}
+
+func TestRunPanics(t *testing.T) {
+	c := chain.New(chain.WithWorkers(1))
+	failure := errors.New("failure")
+	ran := false
+	pred, err := c.Register(func() { panic(failure) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pred.Register(func() { runtime.Goexit() }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pred.After(func() { ran = true }); err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Run()
+	var list chain.ErrorList
+	if !errors.As(err, &list) || len(list) != 2 {
+		t.Fatalf("expected two errors, got %v", err)
+	}
+	var perr *chain.PanicError
+	if !errors.As(err, &perr) || perr.Value != failure {
+		t.Fatalf("expected a PanicError wrapping %v, got %v", failure, err)
+	}
+	if !errors.Is(err, chain.ErrGoexit) {
+		t.Fatalf("expected %v, got %v", chain.ErrGoexit, err)
+	}
+	if !ran {
+		t.Fatal("a failing node should not stop the chain")
+	}
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RunInfo describes a single run of a chain. ID is unique among all runs
+// in the process, Initiator is the label given with InitiatedBy() (if
+// any). Arbitrary values can be attached to a run with Set() and are
+// visible to everything else taking part in it.
+//
+// A run's RunInfo is available from Execution.Info(), Stats.Run and, for
+// funcs passed a context by RunContext(), from RunInfoFrom().
+type RunInfo struct {
+	ID        uint64
+	Start     time.Time
+	Initiator string
+
+	lock   sync.Mutex
+	values map[interface{}]interface{}
+}
+
+var lastRunID uint64
+
+type runInfoKey struct{}
+type initiatorKey struct{}
+
+func newRunInfo(ctx context.Context) *RunInfo {
+	info := &RunInfo{
+		ID:    atomic.AddUint64(&lastRunID, 1),
+		Start: time.Now(),
+	}
+	if ctx != nil {
+		info.Initiator, _ = ctx.Value(initiatorKey{}).(string)
+	}
+	return info
+}
+
+// InitiatedBy returns a context which labels runs started with it (by
+// RunContext() or StartContext()) as having been initiated by label.
+func InitiatedBy(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, initiatorKey{}, label)
+}
+
+// RunInfoFrom returns the RunInfo of the run a context was passed to a
+// func by, or nil.
+func RunInfoFrom(ctx context.Context) *RunInfo {
+	info, _ := ctx.Value(runInfoKey{}).(*RunInfo)
+	return info
+}
+
+// Set attaches a value to the run under key.
+func (r *RunInfo) Set(key, value interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.values == nil {
+		r.values = make(map[interface{}]interface{})
+	}
+	r.values[key] = value
+}
+
+// Get returns the value attached to the run under key.
+func (r *RunInfo) Get(key interface{}) (interface{}, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	v, ok := r.values[key]
+	return v, ok
+}
@@ -0,0 +1,36 @@
+package chain_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestRunInfo(t *testing.T) {
+	var seen *chain.RunInfo
+	c := chain.New(chain.WithStatsCallback(func(s *chain.Stats) {
+		if v, _ := s.Run.Get("key"); v != "value" {
+			t.Errorf("stats callback did not see value set by func")
+		}
+	}))
+	if _, err := c.Register(func(ctx context.Context) {
+		seen = chain.RunInfoFrom(ctx)
+		seen.Set("key", "value")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	e := c.StartContext(chain.InitiatedBy(context.Background(), "test"))
+	if err := e.Err(); err != nil {
+		t.Fatal(err)
+	}
+	info := e.Info()
+	if seen != info || info.Initiator != "test" || info.ID == 0 || info.Start.IsZero() {
+		t.Fatalf("unexpected run info %+v", info)
+	}
+	next := c.StartContext(context.Background())
+	if next.Wait(); next.Info().ID == info.ID {
+		t.Fatal("runs should have unique IDs")
+	}
+}
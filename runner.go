@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"reflect"
+)
+
+type errRunner interface {
+	Run(...interface{}) error
+}
+
+// runnerProxy adapts a Runner (or errRunner) to CallProxy.
+type runnerProxy struct {
+	r interface{}
+}
+
+// asRunner returns a func or CallProxy to register in place of a non-func
+// value, or nil if val isn't runnable. On typed chains a Run method whose
+// signature is compatible with the chain's type is preferred as it can be
+// called directly.
+func asRunner(chain Call, val reflect.Value) interface{} {
+	if cn, ok := chain.(*chainNode); ok && cn.ftype != nil {
+		if m := val.MethodByName("Run"); m.IsValid() && m.Type().ConvertibleTo(cn.ftype) {
+			return m.Convert(cn.ftype).Interface()
+		}
+	}
+	switch r := val.Interface().(type) {
+	case Runner, errRunner:
+		return runnerProxy{r}
+	}
+	return nil
+}
+
+func (p runnerProxy) Call(in []reflect.Value) []reflect.Value {
+	args := make([]interface{}, len(in))
+	for i, v := range in {
+		args[i] = v.Interface()
+	}
+	if _, ok := p.r.(errRunner); ok {
+		err := p.run(args)
+		return []reflect.Value{reflect.ValueOf(&err).Elem()}
+	}
+	p.run(args)
+	return nil
+}
+
+// calls the Runner without any reflection, runs of untyped chains call
+// this directly
+func (p runnerProxy) run(args []interface{}) error {
+	switch r := p.r.(type) {
+	case Runner:
+		r.Run(args...)
+	case errRunner:
+		return r.Run(args...)
+	}
+	return nil
+}
+
+func (p runnerProxy) Unwrap() interface{} {
+	return p.r
+}
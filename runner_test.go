@@ -0,0 +1,90 @@
+package chain_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+type recorder struct {
+	args []interface{}
+}
+
+func (r *recorder) Run(args ...interface{}) {
+	r.args = args
+}
+
+type typedRunner struct {
+	got int
+}
+
+func (r *typedRunner) Run(i int) {
+	r.got = i
+}
+
+type TestIntFunc func(int)
+
+func TestRunner(t *testing.T) {
+	c := chain.New()
+	r := &recorder{}
+	if _, err := c.Register(r); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Run("a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.args) != 2 || r.args[0] != "a" || r.args[1] != 1 {
+		t.Fatalf("runner got %v", r.args)
+	}
+	if _, ok := c.FindFunc(r); !ok {
+		t.Fatal("runner could not be found by identity")
+	}
+
+	typed := chain.NewTyped(TestIntFunc(nil))
+	tr := &typedRunner{}
+	if _, err := typed.Register(tr); err != nil {
+		t.Fatal(err)
+	}
+	if err := typed.Run(7); err != nil || tr.got != 7 {
+		t.Fatalf("typed runner got %d (%v)", tr.got, err)
+	}
+}
+
+func TestNestedChain(t *testing.T) {
+	failure := errors.New("failure")
+	inner := chain.New()
+	if _, err := inner.Register(func(s string) error {
+		return failure
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	outer := chain.New()
+	if _, err := outer.Register(inner); err != nil {
+		t.Fatal(err)
+	}
+	if err := outer.Run("x"); !errors.Is(err, failure) {
+		t.Fatalf("expected %v from nested chain, got %v", failure, err)
+	}
+}
+
+type sumRunner struct{ total *int }
+
+func (s sumRunner) Run(args ...interface{}) error {
+	for _, a := range args {
+		*s.total += a.(int)
+	}
+	return nil
+}
+
+func TestRunnerDirect(t *testing.T) {
+	total := 0
+	c := chain.New()
+	if _, err := c.Register(sumRunner{&total}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Run(1, 2, 3); err != nil || total != 6 {
+		t.Fatalf("unexpected result %d, %v", total, err)
+	}
+}
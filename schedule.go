@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Timing determines when a scheduled chain next runs. Next returns the
+// first run time after t, or the zero time if there are no more runs.
+type Timing interface {
+	Next(t time.Time) time.Time
+}
+
+type interval time.Duration
+
+// Every returns a Timing for runs at a fixed interval.
+func Every(d time.Duration) Timing {
+	return interval(d)
+}
+
+func (i interval) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(i))
+}
+
+// Scheduled is returned by Schedule() and controls the scheduling
+// goroutine.
+type Scheduled struct {
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	runs    sync.WaitGroup
+	busy    int32
+	skipped int64
+}
+
+// Schedule runs r with args at the times given by when, each delayed by a
+// random amount of up to jitter so that many processes on the same
+// schedule don't all run at once. A run that is due while the previous one
+// is still in progress is skipped. Errors returned by runs are reported
+// only to the chain's error handler (see WithErrorHandler).
+func Schedule(r Root, when Timing, jitter time.Duration, args ...interface{}) *Scheduled {
+	s := &Scheduled{stop: make(chan struct{}), done: make(chan struct{})}
+	go s.schedule(r, when, jitter, args)
+	return s
+}
+
+func (s *Scheduled) schedule(r Root, when Timing, jitter time.Duration, args []interface{}) {
+	defer close(s.done)
+	defer s.runs.Wait()
+	for last := time.Now(); ; {
+		next := when.Next(last)
+		if next.IsZero() {
+			return
+		}
+		last = next
+		delay := time.Until(next)
+		if jitter > 0 {
+			delay += time.Duration(rand.Int63n(int64(jitter)))
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if !atomic.CompareAndSwapInt32(&s.busy, 0, 1) {
+			atomic.AddInt64(&s.skipped, 1)
+			continue
+		}
+		s.runs.Add(1)
+		go func() {
+			defer s.runs.Done()
+			defer atomic.StoreInt32(&s.busy, 0)
+			r.Run(args...)
+		}()
+	}
+}
+
+// Stop cancels all future runs and waits for any run in progress to
+// finish.
+func (s *Scheduled) Stop() {
+	s.once.Do(func() { close(s.stop) })
+	<-s.done
+}
+
+// Skipped returns the number of runs skipped because the previous run was
+// still in progress.
+func (s *Scheduled) Skipped() int {
+	return int(atomic.LoadInt64(&s.skipped))
+}
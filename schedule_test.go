@@ -0,0 +1,56 @@
+package chain_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestCron(t *testing.T) {
+	base := time.Date(2014, time.March, 14, 10, 7, 30, 0, time.UTC)
+	for _, tc := range []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2014, time.March, 14, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2014, time.March, 14, 10, 15, 0, 0, time.UTC)},
+		{"0 9-17 * * 1-5", time.Date(2014, time.March, 14, 11, 0, 0, 0, time.UTC)},
+		{"30 2 * * 0", time.Date(2014, time.March, 16, 2, 30, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2014, time.March, 21, 0, 0, 0, 0, time.UTC)},
+	} {
+		timing, err := chain.Cron(tc.expr)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.expr, err)
+		}
+		if next := timing.Next(base); !next.Equal(tc.next) {
+			t.Errorf("%q: expected %v, got %v", tc.expr, tc.next, next)
+		}
+	}
+	for _, bad := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := chain.Cron(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	var runs int32
+	c := chain.New()
+	if _, err := c.Register(func() { atomic.AddInt32(&runs, 1) }); err != nil {
+		t.Fatal(err)
+	}
+	s := chain.Schedule(c, chain.Every(5*time.Millisecond), time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	s.Stop()
+	n := atomic.LoadInt32(&runs)
+	if n < 2 {
+		t.Fatalf("expected several runs, got %d", n)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if atomic.LoadInt32(&runs) != n {
+		t.Fatal("chain ran after Stop()")
+	}
+}
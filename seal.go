@@ -0,0 +1,35 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+func (cn *chainNode) Seal() {
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	cn.opts.sealed = true
+}
+
+func (cn *chainNode) Sealed() bool {
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	return cn.opts.sealed
+}
+
+// returns ErrSealed if the chain has been sealed, must be called with the
+// chain locked.
+func (cn *chainNode) checkSealed() error {
+	if cn.opts.sealed {
+		return ErrSealed
+	}
+	return nil
+}
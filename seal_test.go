@@ -0,0 +1,47 @@
+package chain_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestSeal(t *testing.T) {
+	c := chain.New()
+	pred, err := c.Register(func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Seal()
+	if !c.Sealed() {
+		t.Fatal("chain should be sealed")
+	}
+
+	for name, fn := range map[string]func() error{
+		"Register": func() error { _, err := pred.Register(func() {}); return err },
+		"After":    func() error { _, err := pred.After(func() {}); return err },
+		"First":    func() error { _, err := pred.First(func() {}); return err },
+		"SetName":  func() error { return pred.SetName("sealed") },
+		"Splice":   func() error { return pred.SpliceAfter(chain.New()) },
+		"Batch":    func() error { return c.Batch(func(chain.Builder) error { return nil }) },
+	} {
+		if err := fn(); !errors.Is(err, chain.ErrSealed) {
+			t.Errorf("%s: expected %v, got %v", name, chain.ErrSealed, err)
+		}
+	}
+	if l := c.Snapshot().Len(); l != 1 {
+		t.Fatalf("sealed chain was modified, %d funcs", l)
+	}
+	if err = c.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	clone := c.Clone()
+	if clone.Sealed() {
+		t.Fatal("clones should not be sealed")
+	}
+	if _, err = clone.Register(func() {}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+func (cn *chainNode) RunSelect(sel func(FuncInfo, []interface{}) Decision, args ...interface{}) error {
+	return cn.Snapshot().RunSelect(sel, args...)
+}
+
+func (cn *chainNode) StartSelect(sel func(FuncInfo, []interface{}) Decision, args ...interface{}) *Execution {
+	return cn.Snapshot().StartSelect(sel, args...)
+}
+
+// RunSelect runs the snapshot exactly as Root.RunSelect() would.
+func (s *Snapshot) RunSelect(sel func(FuncInfo, []interface{}) Decision, args ...interface{}) error {
+	return s.opts.handle(s.StartSelect(sel, args...).Err())
+}
+
+// StartSelect is the asynchronous form of RunSelect.
+func (s *Snapshot) StartSelect(sel func(FuncInfo, []interface{}) Decision, args ...interface{}) *Execution {
+	return s.start(&Execution{selector: sel}, nil, args)
+}
+
+// selection tracks the decisions of a run's selector while it is planned
+type selection struct {
+	chain, node bool
+}
+
+// asks the run's selector whether a func should be skipped, once the
+// selector has decided on StopNode or StopChain it isn't asked again
+func (e *Execution) selects(sel *selection, ent *entry, node int, args []interface{}) (skip bool) {
+	if sel.chain || sel.node {
+		return true
+	}
+	switch e.selector(ent.info(node), args) {
+	case Skip:
+		return true
+	case StopNode:
+		sel.node = true
+		return true
+	case StopChain:
+		sel.chain = true
+		return true
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package chain_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestRunSelect(t *testing.T) {
+	var lock sync.Mutex
+	var ran []string
+	record := func(name string) func() {
+		return func() {
+			lock.Lock()
+			defer lock.Unlock()
+			ran = append(ran, name)
+		}
+	}
+	c := chain.New(chain.WithWorkers(1))
+	p, err := c.Register(record("a"), record("b"), record("c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err = p.After(record("d"), record("e")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.After(record("f")); err != nil {
+		t.Fatal(err)
+	}
+	// the selector is asked about each func in order before any run
+	decisions := []chain.Decision{chain.Continue, chain.Skip, chain.Continue, chain.StopNode, chain.Continue}
+	asked := 0
+	sel := func(fn chain.FuncInfo, args []interface{}) chain.Decision {
+		asked++
+		return decisions[asked-1]
+	}
+	e := c.StartSelect(sel)
+	if err = e.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "c", "f"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+	for i, want := range []int{1, 2, 0} {
+		if got := e.Stats().Nodes[i].Skipped; got != want {
+			t.Errorf("node %d skipped %d, want %d", i, got, want)
+		}
+	}
+
+	ran = nil
+	asked = 0
+	stop := func(fn chain.FuncInfo, args []interface{}) chain.Decision {
+		if asked++; asked == 3 {
+			return chain.StopChain
+		}
+		return chain.Continue
+	}
+	if err = c.RunSelect(stop); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"sync"
+)
+
+// WithSingleflight makes concurrent calls to Root.Run() share a single
+// run: a call made while a run started by another call with the same key
+// is in progress doesn't start a run of its own but waits for that one
+// and returns its result. key derives the key from the arguments passed
+// to Run(); if nil every call has the same key. Calls that arrive after a
+// run finishes start a new one.
+func WithSingleflight(key func(args []interface{}) string) Option {
+	return func(o *options) {
+		o.flight = &flight{key: key}
+	}
+}
+
+// flight tracks the runs in progress for each key
+type flight struct {
+	key      func([]interface{}) string
+	lock     sync.Mutex
+	inflight map[string]*coalesced
+}
+
+// returns a flight with the same key func, used when cloning chains
+func (f *flight) fresh() *flight {
+	if f == nil {
+		return nil
+	}
+	return &flight{key: f.key}
+}
+
+func (f *flight) run(cn *chainNode, args []interface{}) error {
+	var key string
+	if f.key != nil {
+		key = f.key(args)
+	}
+	f.lock.Lock()
+	if c, ok := f.inflight[key]; ok {
+		f.lock.Unlock()
+		<-c.done
+		return c.err
+	}
+	c := &coalesced{args: args, done: make(chan struct{})}
+	if f.inflight == nil {
+		f.inflight = make(map[string]*coalesced)
+	}
+	f.inflight[key] = c
+	f.lock.Unlock()
+
+	c.err = cn.Snapshot().Run(args...)
+	f.lock.Lock()
+	delete(f.inflight, key)
+	f.lock.Unlock()
+	close(c.done)
+	return c.err
+}
@@ -0,0 +1,50 @@
+package chain_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestSingleflight(t *testing.T) {
+	var runs, calls int32
+	started, joined, release := make(chan struct{}), make(chan struct{}), make(chan struct{})
+	c := chain.New(chain.WithSingleflight(func([]interface{}) string {
+		if atomic.AddInt32(&calls, 1) == 4 {
+			close(joined)
+		}
+		return "reload"
+	}))
+	c.Register(func() {
+		if atomic.AddInt32(&runs, 1) == 1 {
+			close(started)
+		}
+		<-release
+	})
+
+	var wg sync.WaitGroup
+	run := func() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Run()
+		}()
+	}
+	run()
+	<-started
+	for i := 0; i < 3; i++ {
+		run()
+	}
+	// every call has its key and the first run can't finish yet, give
+	// them a moment to find it
+	<-joined
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := atomic.LoadInt32(&runs); n != 1 {
+		t.Fatalf("expected concurrent runs to be collapsed, got %d", n)
+	}
+}
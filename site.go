@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// RegistrationError is returned by Register() and friends when a func
+// cannot be registered. Site identifies the source file and line the
+// registration was attempted from.
+type RegistrationError struct {
+	Site string
+	Err  error
+}
+
+func (e *RegistrationError) Error() string {
+	return e.Site + ": " + e.Err.Error()
+}
+
+func (e *RegistrationError) Unwrap() error {
+	return e.Err
+}
+
+func siteError(site string, err error) error {
+	if err == nil || site == "" {
+		return err
+	}
+	if _, ok := err.(*RegistrationError); ok {
+		return err
+	}
+	return &RegistrationError{Site: site, Err: err}
+}
+
+var pkgPrefix = reflect.TypeOf(chainNode{}).PkgPath() + "."
+
+// callSite returns "dir/file.go:line" for the first caller outside of this
+// package.
+func callSite() string {
+	var pc [16]uintptr
+	frames := runtime.CallersFrames(pc[:runtime.Callers(2, pc[:])])
+	for {
+		f, more := frames.Next()
+		if !strings.HasPrefix(f.Function, pkgPrefix) {
+			return fmt.Sprintf("%s:%d", filepath.Join(filepath.Base(filepath.Dir(f.File)), filepath.Base(f.File)), f.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// funcName returns the fully qualified name of the func behind a
+// CallProxy, or the proxy's type if it isn't a reflected func.
+func funcName(cp CallProxy) string {
+	if p, ok := cp.(prefixProxy); ok {
+		cp = p.fn
+	}
+	if val, ok := cp.(reflect.Value); ok && val.Kind() == reflect.Func {
+		if f := runtime.FuncForPC(val.Pointer()); f != nil {
+			return f.Name()
+		}
+	}
+	return fmt.Sprintf("%T", cp)
+}
+
+func (cn *chainNode) Dump(w io.Writer) error {
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+
+	pos := 0
+	for n := cn.getFirst(); n != nil; n, pos = n.getNext(), pos+1 {
+		if _, err := fmt.Fprintf(w, "node %d:\n", pos); err != nil {
+			return err
+		}
+		for _, e := range n.funcs {
+			if _, err := fmt.Fprintf(w, "\t%s %v registered at %s\n",
+				funcName(e.fn), funcType(e.fn), e.site); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// Snapshot is a frozen copy of a call chain's node and func layout. Funcs
+// registered with the originating chain after the snapshot was taken are
+// not seen by it, so a snapshot can be run any number of times while the
+// chain continues to be modified concurrently.
+type Snapshot struct {
+	nodes    []snapNode
+	opts     *options
+	ftype    reflect.Type
+	expiring bool
+}
+
+type snapNode struct {
+	node  *chainNode
+	name  string
+	funcs []*entry
+	deps  []dependency
+
+	onError func(FuncInfo, error) Decision
+}
+
+// snapCache holds the most recent snapshot of an entire chain. Snapshots
+// are immutable so as long as the chain isn't modified the same one can be
+// handed to every run without taking the chain lock; any modification
+// discards it and the next run takes a new one. That run takes the chain
+// lock to do so, and so does contend with writers: copying the chain on
+// every modification instead would make building a chain quadratic in its
+// size. Chains with expiring funcs (see MaxRuns) are never cached.
+type snapCache struct {
+	v atomic.Value
+}
+
+func (c *snapCache) load() *Snapshot {
+	s, _ := c.v.Load().(*Snapshot)
+	return s
+}
+
+// must be called with the chain locked, as must invalidate()
+func (c *snapCache) store(s *Snapshot) {
+	c.v.Store(s)
+}
+
+func (c *snapCache) invalidate() {
+	if c.load() != nil {
+		c.v.Store((*Snapshot)(nil))
+	}
+}
+
+// Snapshot returns a frozen copy of the entire chain as it currently
+// exists. Run() and friends always operate on an up to date snapshot.
+func (cn *chainNode) Snapshot() *Snapshot {
+	if s := cn.opts.cache.load(); s != nil {
+		return s
+	}
+	defer cn.notify()
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	cn.prune()
+	s := cn.snapshot()
+	// chains with expiring funcs must be pruned by every run
+	if !s.expiring {
+		cn.opts.cache.store(s)
+	}
+	return s
+}
+
+// must be called with the chain locked
+func (cn *chainNode) snapshot() *Snapshot {
+	return cn.snapshotRange(nil, nil)
+}
+
+// snapshots the nodes from the first to the last (inclusive), either of
+// which may be nil to indicate the head or tail of the chain. Must be
+// called with the chain locked.
+func (cn *chainNode) snapshotRange(first, last *chainNode) *Snapshot {
+	s := &Snapshot{opts: cn.opts.clone(), ftype: cn.ftype}
+	n := cn.getFirst()
+	if first != nil {
+		for ; n != nil && n != first; n = n.getNext() {
+			// nop
+		}
+		if n == nil {
+			panic("chain: node does not belong to this chain")
+		}
+	}
+	// size everything up front so that the snapshot's nodes and the funcs
+	// of all of them are each packed into a single allocation. The funcs
+	// are copied as the chain frees the entries of funcs it removes.
+	nodes, funcs := 0, 0
+	end := n
+	for ; end != nil; end = end.getNext() {
+		nodes++
+		funcs += len(end.funcs)
+		if end == last {
+			break
+		}
+	}
+	if last != nil && end == nil {
+		panic("chain: node does not belong to this chain")
+	}
+	s.nodes = make([]snapNode, 0, nodes)
+	entries := make([]entry, 0, funcs)
+	packed := make([]*entry, 0, funcs)
+	for ; n != nil; n = n.getNext() {
+		for _, e := range n.funcs {
+			entries = append(entries, *e)
+			packed = append(packed, &entries[len(entries)-1])
+			if e.expiry != nil {
+				s.expiring = true
+			}
+		}
+		sn := snapNode{node: n, name: n.name, funcs: packed[len(packed)-len(n.funcs) : len(packed) : len(packed)], deps: n.deps, onError: n.onError}
+		s.nodes = append(s.nodes, sn)
+		if n == last {
+			break
+		}
+	}
+	return s
+}
+
+// RunFrom runs only the portion of the chain starting with p, skipping
+// all nodes that come before it.
+func (cn *chainNode) RunFrom(p Predicate, args ...interface{}) error {
+	cn.lock.Lock()
+	cn.prune()
+	s := cn.snapshotRange(p.(*chainNode), nil)
+	cn.lock.Unlock()
+	cn.notify()
+	return s.Run(args...)
+}
+
+// RunUntil runs only the portion of the chain up to and including p,
+// skipping all nodes that come after it.
+func (cn *chainNode) RunUntil(p Predicate, args ...interface{}) error {
+	cn.lock.Lock()
+	cn.prune()
+	s := cn.snapshotRange(nil, p.(*chainNode))
+	cn.lock.Unlock()
+	cn.notify()
+	return s.Run(args...)
+}
+
+// Len returns the total number of funcs in the snapshot.
+func (s *Snapshot) Len() (l int) {
+	for _, n := range s.nodes {
+		l += len(n.funcs)
+	}
+	return
+}
+
+// Reverse returns a copy of the snapshot with its nodes in the opposite
+// order, which is handy for running teardown in the reverse of setup.
+func (s *Snapshot) Reverse() *Snapshot {
+	r := *s
+	r.nodes = make([]snapNode, len(s.nodes))
+	for i, n := range s.nodes {
+		r.nodes[len(s.nodes)-1-i] = n
+	}
+	return &r
+}
+
+// Run runs the snapshot exactly as Root.Run() would.
+func (s *Snapshot) Run(args ...interface{}) error {
+	return s.opts.handle(s.Start(args...).Err())
+}
+
+// RunFiltered runs the snapshot exactly as Root.RunFiltered() would.
+func (s *Snapshot) RunFiltered(filter func(interface{}, []interface{}) bool,
+	args ...interface{}) error {
+	return s.opts.handle(s.StartFiltered(filter, args...).Err())
+}
+
+// Start is the asynchronous form of Run.
+func (s *Snapshot) Start(args ...interface{}) *Execution {
+	return s.start(&Execution{}, nil, args)
+}
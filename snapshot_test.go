@@ -0,0 +1,87 @@
+package chain_test
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestSnapshot(t *testing.T) {
+	var calls int32
+	inc := func() { atomic.AddInt32(&calls, 1) }
+
+	c := chain.New()
+	pred, err := c.Register(inc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pred.After(inc); err != nil {
+		t.Fatal(err)
+	}
+	snap := c.Snapshot()
+
+	// neither a new func nor a new node should be seen by the snapshot
+	if _, err = pred.Register(inc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pred.Before(inc); err != nil {
+		t.Fatal(err)
+	}
+	if l := snap.Len(); l != 2 {
+		t.Fatalf("snapshot should have 2 funcs, not %d", l)
+	}
+	snap.Run()
+	if calls != 2 {
+		t.Fatalf("snapshot run made %d calls instead of 2", calls)
+	}
+
+	calls = 0
+	c.Run()
+	if calls != 4 {
+		t.Fatalf("chain run made %d calls instead of 4", calls)
+	}
+}
+
+func TestRegisterDuringRun(t *testing.T) {
+	release := make(chan struct{})
+	c := chain.New()
+	pred, err := c.Register(func() { <-release })
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := c.Start()
+	// would deadlock if the run still held the chain lock
+	if _, err = pred.After(func() {}); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if n := e.Stats().Nodes; len(n) != 1 {
+		t.Fatalf("in-flight run should only see 1 node, saw %d", len(n))
+	}
+}
+
+func TestSnapshotReuse(t *testing.T) {
+	c := chain.New()
+	pred, err := c.Register(func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := c.Snapshot()
+	if c.Snapshot() != s {
+		t.Fatal("unmodified chain should reuse its snapshot")
+	}
+	if _, err = pred.After(func() {}); err != nil {
+		t.Fatal(err)
+	}
+	s2 := c.Snapshot()
+	if s2 == s || s2.Len() != 2 || s.Len() != 1 {
+		t.Fatal("modifying the chain should replace its snapshot")
+	}
+	if err = pred.SetName("first"); err != nil {
+		t.Fatal(err)
+	}
+	if c.Snapshot() == s2 {
+		t.Fatal("renaming a node should replace the snapshot")
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"sync/atomic"
+)
+
+// RunState is the state of a single run, see Execution.State().
+type RunState int32
+
+const (
+	// A stepped run whose first node hasn't been released yet.
+	Idle RunState = iota
+	// Funcs are running or waiting to run.
+	Running
+	// Every func ran without failing.
+	Completed
+	// The run couldn't be started or at least one func failed.
+	Failed
+	// The run was cut short, either because its context was done or by
+	// a StopChain decision (see Predicate.OnError).
+	Aborted
+)
+
+func (s RunState) String() string {
+	switch s {
+	case Idle:
+		return "Idle"
+	case Running:
+		return "Running"
+	case Completed:
+		return "Completed"
+	case Failed:
+		return "Failed"
+	case Aborted:
+		return "Aborted"
+	}
+	return "RunState(?)"
+}
+
+// State returns the current state of the run without waiting for it to
+// finish. Once it is Completed, Failed or Aborted it never changes again.
+func (e *Execution) State() RunState {
+	return RunState(atomic.LoadInt32(&e.state))
+}
+
+// Done returns a channel which is closed once the run has finished, for
+// use in select statements where Wait() won't do.
+func (e *Execution) Done() <-chan struct{} {
+	return e.done
+}
+
+func (e *Execution) setState(s RunState) {
+	atomic.StoreInt32(&e.state, int32(s))
+}
+
+// decides the final state of a run, once all the workers have exited
+func (e *Execution) settle() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	switch {
+	case e.canceled || e.abandoned || atomic.LoadInt32(&e.aborted) != 0:
+		e.setState(Aborted)
+	case e.err != nil || len(e.errors) > 0:
+		e.setState(Failed)
+	default:
+		e.setState(Completed)
+	}
+}
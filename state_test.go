@@ -0,0 +1,44 @@
+package chain_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestRunState(t *testing.T) {
+	release := make(chan struct{})
+	c := chain.NewTyped(func() error { return nil })
+	c.Register(func() error {
+		<-release
+		return nil
+	})
+	e := c.Start()
+	if s := e.State(); s != chain.Running {
+		t.Fatalf("expected Running, got %v", s)
+	}
+	close(release)
+	<-e.Done()
+	if s := e.State(); s != chain.Completed {
+		t.Fatalf("expected Completed, got %v", s)
+	}
+
+	c.Register(func() error { return errors.New("fail") })
+	e = c.Start()
+	e.Wait()
+	if s := e.State(); s != chain.Failed {
+		t.Fatalf("expected Failed, got %v", s)
+	}
+
+	e = c.StartStepped()
+	if s := e.State(); s != chain.Idle {
+		t.Fatalf("expected Idle, got %v", s)
+	}
+	for _, ok := e.Step(); ok; _, ok = e.Step() {
+	}
+	e.Wait()
+	if s := e.State(); s != chain.Failed {
+		t.Fatalf("expected Failed, got %v", s)
+	}
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// strictCheck performs the additional registration checks made by chains
+// created WithStrict. ftype may be nil for untyped chains.
+func strictCheck(val reflect.Value, ftype reflect.Type) error {
+	if val.IsNil() {
+		return ErrNilFunc
+	}
+	if ftype == nil {
+		return nil
+	}
+	T := val.Type()
+	switch {
+	case T.IsVariadic() && !ftype.IsVariadic():
+		return fmt.Errorf("%v is variadic but %v is not", T, ftype)
+	case !T.IsVariadic() && ftype.IsVariadic():
+		return fmt.Errorf("%v is variadic but %v is not", ftype, T)
+	case T.NumIn() != ftype.NumIn():
+		return fmt.Errorf("%v takes %d parameter(s) but %v passes %d", T, T.NumIn(), ftype, ftype.NumIn())
+	case T.NumOut() != ftype.NumOut():
+		return fmt.Errorf("%v returns %d result(s) but %v expects %d", T, T.NumOut(), ftype, ftype.NumOut())
+	}
+	// funcs are converted to the chain's type, which requires identical
+	// parameter and result types
+	for i := 0; i < T.NumIn(); i++ {
+		if T.In(i) != ftype.In(i) {
+			return fmt.Errorf("parameter %d of %v is %v but %v passes %v",
+				i+1, T, T.In(i), ftype, ftype.In(i))
+		}
+	}
+	for i := 0; i < T.NumOut(); i++ {
+		if T.Out(i) != ftype.Out(i) {
+			return fmt.Errorf("result %d of %v is %v but %v expects %v",
+				i+1, T, T.Out(i), ftype, ftype.Out(i))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package chain_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestStrict(t *testing.T) {
+	c := chain.NewTyped(TestVariadicFunc(nil), chain.WithStrict())
+	for _, test := range []struct {
+		fn   interface{}
+		want string
+	}{
+		{func(*testing.T) {}, "is variadic but"},
+		{func(*testing.T, ...int) {}, "parameter 2"},
+		{func(interface{}, ...string) {}, "parameter 1"},
+		{func(*testing.T, ...string) error { return nil }, "returns 1 result"},
+		{TestVariadicFunc(nil), chain.ErrNilFunc.Error()},
+	} {
+		_, err := c.Register(test.fn)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Fatalf("expected error containing %q for %T, got %v", test.want, test.fn, err)
+		}
+	}
+	if _, err := c.Register(func(*testing.T, ...string) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	u := chain.New(chain.WithStrict())
+	var nilFunc func()
+	if _, err := u.Register(nilFunc); !errors.Is(err, chain.ErrNilFunc) {
+		t.Fatalf("expected nil func to be rejected, got %v", err)
+	}
+	if _, err := u.Register(nil); !errors.Is(err, chain.ErrNilFunc) {
+		t.Fatalf("expected untyped nil to be rejected, got %v", err)
+	}
+	if n := u.Len(); n != 0 {
+		t.Fatalf("chain has %d funcs after rejected registrations", n)
+	}
+
+	p := chain.NewTyped(TestVariadicFunc(nil), chain.WithStrict(), chain.WithPrefixFuncs())
+	var nilPrefix func(*testing.T)
+	if _, err := p.Register(nilPrefix); !errors.Is(err, chain.ErrNilFunc) {
+		t.Fatalf("expected nil prefix func to be rejected, got %v", err)
+	}
+	if _, err := p.Register(func(*testing.T) {}); err != nil {
+		t.Fatal(err)
+	}
+}
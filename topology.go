@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTopologyMismatch is returned (wrapped) by Topology.Verify().
+var ErrTopologyMismatch = errors.New("chain topology mismatch")
+
+// Topology is a description of the layout of a chain which, unlike the
+// chain itself, can be encoded with encoding/gob or encoding/json. This
+// allows a process to ship the layout it expects to another and have it
+// verified there. Nodes are listed in execution order.
+type Topology struct {
+	Nodes []TopologyNode `json:"nodes"`
+}
+
+// TopologyNode describes a single node. ID is its position in the chain
+// and After the ID of the node it runs after (-1 for the head). WaitFor
+// lists the names of the nodes in other chains it waits for (see
+// Predicate.WaitFor).
+type TopologyNode struct {
+	ID      int            `json:"id"`
+	Name    string         `json:"name,omitempty"`
+	After   int            `json:"after"`
+	WaitFor []string       `json:"waitFor,omitempty"`
+	Funcs   []TopologyFunc `json:"funcs,omitempty"`
+}
+
+// TopologyFunc describes a registered func by its symbol, the name it was
+// registered under, its type and where it was registered from (see
+// FuncInfo).
+type TopologyFunc struct {
+	Symbol string `json:"symbol"`
+	Name   string `json:"name,omitempty"`
+	Type   string `json:"type"`
+	Site   string `json:"site,omitempty"`
+}
+
+// TopologyOf returns the current topology of a chain.
+func TopologyOf(r Root) *Topology {
+	cn, ok := asNode(r)
+	if !ok {
+		return &Topology{}
+	}
+	t := &Topology{}
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	for n := cn.getFirst(); n != nil; n = n.getNext() {
+		id := len(t.Nodes)
+		tn := TopologyNode{ID: id, Name: n.name, After: id - 1}
+		for _, d := range n.deps {
+			tn.WaitFor = append(tn.WaitFor, d.name)
+		}
+		for _, e := range n.funcs {
+			fi := e.info(id)
+			tf := TopologyFunc{Symbol: fi.Symbol, Name: fi.Name, Site: fi.Site}
+			if fi.Type != nil {
+				tf.Type = fi.Type.String()
+			}
+			tn.Funcs = append(tn.Funcs, tf)
+		}
+		t.Nodes = append(t.Nodes, tn)
+	}
+	return t
+}
+
+// Verify checks that a chain has the topology described by the receiver,
+// ignoring registration sites. The first difference found is reported
+// as an error wrapping ErrTopologyMismatch.
+func (t *Topology) Verify(r Root) error {
+	actual := TopologyOf(r)
+	if len(actual.Nodes) != len(t.Nodes) {
+		return fmt.Errorf("%w: expected %d nodes, found %d", ErrTopologyMismatch, len(t.Nodes), len(actual.Nodes))
+	}
+	for i, want := range t.Nodes {
+		if err := want.verify(&actual.Nodes[i]); err != nil {
+			return fmt.Errorf("%w: node %d: %v", ErrTopologyMismatch, i, err)
+		}
+	}
+	return nil
+}
+
+func (want *TopologyNode) verify(got *TopologyNode) error {
+	switch {
+	case want.ID != got.ID || want.After != got.After:
+		return fmt.Errorf("expected id %d after %d, found id %d after %d", want.ID, want.After, got.ID, got.After)
+	case want.Name != got.Name:
+		return fmt.Errorf("expected name %q, found %q", want.Name, got.Name)
+	case fmt.Sprint(want.WaitFor) != fmt.Sprint(got.WaitFor):
+		return fmt.Errorf("expected to wait for %v, found %v", want.WaitFor, got.WaitFor)
+	case len(want.Funcs) != len(got.Funcs):
+		return fmt.Errorf("expected %d funcs, found %d", len(want.Funcs), len(got.Funcs))
+	}
+	for i, f := range want.Funcs {
+		g := got.Funcs[i]
+		if f.Symbol != g.Symbol || f.Name != g.Name || f.Type != g.Type {
+			return fmt.Errorf("func %d: expected %s %q (%s), found %s %q (%s)", i, f.Symbol, f.Name, f.Type, g.Symbol, g.Name, g.Type)
+		}
+	}
+	return nil
+}
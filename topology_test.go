@@ -0,0 +1,49 @@
+package chain_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func topologyFunc(int) {}
+
+func TestTopology(t *testing.T) {
+	build := func() chain.Root {
+		c := chain.New()
+		p, _ := c.Register(topologyFunc)
+		p.SetName("first")
+		p.After(func() {})
+		return c
+	}
+	c := build()
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(chain.TopologyOf(c)); err != nil {
+		t.Fatal(err)
+	}
+	var shipped chain.Topology
+	if err := gob.NewDecoder(&buf).Decode(&shipped); err != nil {
+		t.Fatal(err)
+	}
+	if err := shipped.Verify(build()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(chain.TopologyOf(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded chain.Topology
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	c.Register(func() {})
+	if err = decoded.Verify(c); !errors.Is(err, chain.ErrTopologyMismatch) {
+		t.Fatalf("expected mismatch, got %v", err)
+	}
+}
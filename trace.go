@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TraceKind identifies what a TraceEvent records.
+type TraceKind int
+
+const (
+	// A func was called.
+	TraceFuncStart TraceKind = iota
+	// A func returned (or panicked).
+	TraceFuncEnd
+	// A func was not called (see WithStopOnError, RunContext and
+	// Idempotent).
+	TraceFuncSkipped
+)
+
+func (k TraceKind) String() string {
+	switch k {
+	case TraceFuncStart:
+		return "FuncStart"
+	case TraceFuncEnd:
+		return "FuncEnd"
+	case TraceFuncSkipped:
+		return "FuncSkipped"
+	}
+	return "TraceKind(?)"
+}
+
+// TraceEvent is a single entry in a chain's trace. Run is the ID of the run
+// the event belongs to (see RunInfo), Node the position of the func's node
+// within the run and Goroutine the id of the goroutine the func was (or
+// would have been) called from. Duration and Err are only set for
+// TraceFuncEnd, Err also being set for funcs skipped because the run's
+// context was done.
+type TraceEvent struct {
+	Run       uint64
+	Kind      TraceKind
+	Time      time.Time
+	Node      int
+	Func      interface{}
+	Goroutine int64
+	Duration  time.Duration
+	Err       error
+}
+
+// traceRing holds the last len(buf) trace events. A nil traceRing
+// discards everything.
+type traceRing struct {
+	lock sync.Mutex
+	buf  []TraceEvent
+	next int
+	full bool
+}
+
+func newTraceRing(n int) *traceRing {
+	if n <= 0 {
+		return nil
+	}
+	return &traceRing{buf: make([]TraceEvent, n)}
+}
+
+// returns an empty ring of the same size, used when cloning chains
+func (t *traceRing) fresh() *traceRing {
+	if t == nil {
+		return nil
+	}
+	return newTraceRing(len(t.buf))
+}
+
+func (t *traceRing) add(run *RunInfo, kind TraceKind, c call, start time.Time, err error) {
+	if t == nil {
+		return
+	}
+	ev := TraceEvent{
+		Run:       run.ID,
+		Kind:      kind,
+		Time:      time.Now(),
+		Node:      c.node,
+		Func:      c.id,
+		Goroutine: goroutineID(),
+		Err:       err,
+	}
+	if !start.IsZero() {
+		ev.Duration = ev.Time.Sub(start)
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.buf[t.next] = ev
+	if t.next++; t.next == len(t.buf) {
+		t.next = 0
+		t.full = true
+	}
+}
+
+func (t *traceRing) events() []TraceEvent {
+	if t == nil {
+		return nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.full {
+		return append([]TraceEvent(nil), t.buf[:t.next]...)
+	}
+	return append(append([]TraceEvent(nil), t.buf[t.next:]...), t.buf[:t.next]...)
+}
+
+func (cn *chainNode) Trace() []TraceEvent {
+	return cn.opts.trace.events()
+}
+
+// goroutineID parses the current goroutine's id out of its stack trace.
+// It's slow, but only used when tracing.
+func goroutineID() int64 {
+	var buf [64]byte
+	b := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		id, _ := strconv.ParseInt(string(b[:i]), 10, 64)
+		return id
+	}
+	return 0
+}
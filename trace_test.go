@@ -0,0 +1,52 @@
+package chain_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestTrace(t *testing.T) {
+	failure := errors.New("failure")
+	c := chain.New(chain.WithTrace(4))
+	pred, err := c.Register(func() error {
+		time.Sleep(5 * time.Millisecond)
+		return failure
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pred.After(func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Trace()) != 0 {
+		t.Fatal("trace should be empty before running")
+	}
+
+	c.Run()
+	trace := c.Trace()
+	if len(trace) != 4 {
+		t.Fatalf("expected 4 trace events, got %d", len(trace))
+	}
+	kinds := []chain.TraceKind{chain.TraceFuncStart, chain.TraceFuncEnd, chain.TraceFuncStart, chain.TraceFuncEnd}
+	for i, ev := range trace {
+		if ev.Kind != kinds[i] || ev.Goroutine == 0 {
+			t.Fatalf("unexpected trace event %d: %+v", i, ev)
+		}
+	}
+	if trace[1].Err != failure || trace[1].Duration < 5*time.Millisecond {
+		t.Fatalf("unexpected end event %+v", trace[1])
+	}
+
+	// a second run overwrites the oldest events
+	c.RunUntil(pred)
+	trace = c.Trace()
+	if len(trace) != 4 || trace[0].Node != 1 || trace[3].Node != 0 || trace[3].Kind != chain.TraceFuncEnd {
+		t.Fatalf("ring buffer did not wrap: %+v", trace)
+	}
+	if len(chain.New().Trace()) != 0 || len(c.Clone().Trace()) != 0 {
+		t.Fatal("expected no trace events")
+	}
+}
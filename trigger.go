@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Overlap determines what Trigger() does with events that arrive while a
+// run started by an earlier event is still in progress.
+type Overlap int
+
+const (
+	// Each event waits for the previous run to finish.
+	OverlapQueue Overlap = iota
+	// Events are discarded while a run is in progress.
+	OverlapDrop
+	// Every event starts a run immediately.
+	OverlapConcurrent
+)
+
+// Subscription is returned by Trigger() and controls the goroutine that
+// receives events.
+type Subscription struct {
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	runs    sync.WaitGroup
+	busy    int32
+	dropped int64
+}
+
+// Trigger runs r each time a value is received from events, which must be
+// a channel, passing the value as the only argument. Errors returned by
+// runs are reported only to the chain's error handler (see
+// WithErrorHandler). Events are received until the channel is closed or
+// Stop() is called.
+func Trigger(r Root, events interface{}, overlap Overlap) (*Subscription, error) {
+	ch := reflect.ValueOf(events)
+	if ch.Kind() != reflect.Chan || ch.Type().ChanDir()&reflect.RecvDir == 0 {
+		return nil, ErrNotChan
+	}
+	s := &Subscription{stop: make(chan struct{}), done: make(chan struct{})}
+	go s.receive(r, ch, overlap)
+	return s, nil
+}
+
+func (s *Subscription) receive(r Root, ch reflect.Value, overlap Overlap) {
+	defer close(s.done)
+	defer s.runs.Wait()
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(s.stop)},
+		{Dir: reflect.SelectRecv, Chan: ch},
+	}
+	for {
+		i, v, ok := reflect.Select(cases)
+		if i == 0 || !ok {
+			return
+		}
+		arg := v.Interface()
+		switch overlap {
+		case OverlapDrop:
+			if !atomic.CompareAndSwapInt32(&s.busy, 0, 1) {
+				atomic.AddInt64(&s.dropped, 1)
+				continue
+			}
+			s.runs.Add(1)
+			go func() {
+				defer s.runs.Done()
+				defer atomic.StoreInt32(&s.busy, 0)
+				r.Run(arg)
+			}()
+		case OverlapConcurrent:
+			s.runs.Add(1)
+			go func() {
+				defer s.runs.Done()
+				r.Run(arg)
+			}()
+		default:
+			r.Run(arg)
+		}
+	}
+}
+
+// Stop stops receiving events and waits for any runs in progress to
+// finish.
+func (s *Subscription) Stop() {
+	s.once.Do(func() { close(s.stop) })
+	<-s.done
+}
+
+// Done is closed once the events channel has been closed (or Stop() has
+// been called) and every run has finished.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Dropped returns the number of events discarded by OverlapDrop.
+func (s *Subscription) Dropped() int {
+	return int(atomic.LoadInt64(&s.dropped))
+}
@@ -0,0 +1,61 @@
+package chain_test
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestTrigger(t *testing.T) {
+	var sum int64
+	c := chain.NewTyped(TestIntFunc(nil))
+	if _, err := c.Register(func(i int) { atomic.AddInt64(&sum, int64(i)) }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chain.Trigger(c, 42, chain.OverlapQueue); err != chain.ErrNotChan {
+		t.Fatalf("expected %v, got %v", chain.ErrNotChan, err)
+	}
+
+	events := make(chan int)
+	sub, err := chain.Trigger(c, events, chain.OverlapQueue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 3; i++ {
+		events <- i
+	}
+	close(events)
+	<-sub.Done()
+	if sum != 6 {
+		t.Fatalf("expected 6, got %d", sum)
+	}
+}
+
+func TestTriggerDrop(t *testing.T) {
+	release := make(chan struct{})
+	var runs int32
+	c := chain.NewTyped(TestIntFunc(nil))
+	if _, err := c.Register(func(int) {
+		atomic.AddInt32(&runs, 1)
+		<-release
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan int)
+	sub, err := chain.Trigger(c, events, chain.OverlapDrop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the unbuffered channel guarantees the first event has been received
+	// before the others are sent
+	for i := 0; i < 3; i++ {
+		events <- i
+	}
+	close(release)
+	sub.Stop()
+	if runs != 1 || sub.Dropped() != 2 {
+		t.Fatalf("expected 1 run and 2 dropped events, got %d and %d", runs, sub.Dropped())
+	}
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// WithWaiter replaces the *sync.WaitGroup that every node of a chain is
+// given as its Waiter (see Call.Waiter()) with one returned by factory,
+// which is called once for each node. Waiters which have an Add(int)
+// method are incremented by Iterate() just as a *sync.WaitGroup is. If
+// factory returns nil the node has no waiter at all and its Waiter()
+// method returns ErrChainNoWaiter.
+func WithWaiter(factory func() Waiter) Option {
+	return func(o *options) {
+		o.waiter = factory
+	}
+}
+
+func (o *options) newWaiter() Waiter {
+	if o.waiter == nil {
+		return &sync.WaitGroup{}
+	}
+	return o.waiter()
+}
+
+// waits for w in the background, closing the returned channel once it
+// returns
+func waitChan(w Waiter) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Wait()
+	}()
+	return done
+}
+
+// WaitTimeout waits for w for at most d, returning false if it timed out.
+// Waiters can't be interrupted so a goroutine continues to wait for w
+// after a timeout.
+func WaitTimeout(w Waiter, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-waitChan(w):
+		return true
+	case <-t.C:
+		return false
+	}
+}
+
+// WaitContext waits for w until ctx is done, in which case it returns the
+// context's error. As with WaitTimeout a goroutine continues to wait for w.
+func WaitContext(ctx context.Context, w Waiter) error {
+	select {
+	case <-waitChan(w):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
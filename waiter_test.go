@@ -0,0 +1,61 @@
+package chain_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func TestWaitTimeout(t *testing.T) {
+	if !chain.WaitTimeout(chain.NullWaiter, time.Second) {
+		t.Fatal("NullWaiter timed out")
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	defer wg.Done()
+	if chain.WaitTimeout(&wg, time.Millisecond) {
+		t.Fatal("expected timeout")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := chain.WaitContext(ctx, &wg); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+type countingWaiter struct {
+	sync.WaitGroup
+	added int
+}
+
+func (w *countingWaiter) Add(n int) {
+	w.added += n
+	w.WaitGroup.Add(n)
+}
+
+func TestWithWaiter(t *testing.T) {
+	var waiters []*countingWaiter
+	c := chain.New(chain.WithWaiter(func() chain.Waiter {
+		w := &countingWaiter{}
+		waiters = append(waiters, w)
+		return w
+	}))
+	p, _ := c.Register(func() {}, func() {})
+	for range p.Iterate() {
+	}
+	if len(waiters) != 1 || waiters[0].added != 2 {
+		t.Fatalf("custom waiter not used by Iterate")
+	}
+	if chain.WaitGroup(p) != nil {
+		t.Fatal("expected no sync.WaitGroup")
+	}
+
+	n := chain.New(chain.WithWaiter(func() chain.Waiter { return nil }))
+	if _, err := n.Waiter(); err != chain.ErrChainNoWaiter {
+		t.Fatalf("expected ErrChainNoWaiter, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2014 Jesse Sipprell <jessesipprell@gmail.com>
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ */
+
+package chain
+
+import (
+	"reflect"
+)
+
+// FuncInfo describes a single registered func. Func is the func's identity
+// as passed to RunFiltered filters, Symbol its fully qualified name (if it
+// has one), Name the name it was registered under with RegisterMap (if
+// any), Node the position of its node (counting from the head of the
+// chain, or of the snapshot being run) and Site where it was registered
+// from.
+type FuncInfo struct {
+	Func   interface{}
+	Type   reflect.Type
+	Symbol string
+	Name   string
+	Node   int
+	Site   string
+}
+
+func (e *entry) info(node int) FuncInfo {
+	return FuncInfo{
+		Func:   e.id,
+		Type:   funcType(e.fn),
+		Symbol: funcName(e.fn),
+		Name:   e.name,
+		Node:   node,
+		Site:   e.site,
+	}
+}
+
+func (cn *chainNode) Funcs() (funcs []FuncInfo) {
+	cn.lock.Lock()
+	defer cn.lock.Unlock()
+	pos := 0
+	for n := cn.getFirst(); n != nil; n = n.getNext() {
+		for _, e := range n.funcs {
+			funcs = append(funcs, e.info(pos))
+		}
+		pos++
+	}
+	return
+}
+
+func (cn *chainNode) Walk(visit func(Predicate, []FuncInfo) error) error {
+	type visitNode struct {
+		node  *chainNode
+		funcs []FuncInfo
+	}
+	var nodes []visitNode
+	cn.lock.Lock()
+	for n := cn.getFirst(); n != nil; n = n.getNext() {
+		v := visitNode{node: n, funcs: make([]FuncInfo, len(n.funcs))}
+		for i, e := range n.funcs {
+			v.funcs[i] = e.info(len(nodes))
+		}
+		nodes = append(nodes, v)
+	}
+	cn.lock.Unlock()
+
+	for _, v := range nodes {
+		if err := visit(v.node, v.funcs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
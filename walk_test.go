@@ -0,0 +1,66 @@
+package chain_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jsipprell/go-chain"
+)
+
+func walkFunc() {}
+
+func TestWalk(t *testing.T) {
+	c := chain.New()
+	pred, err := c.Register(walkFunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = pred.After(func() {}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.RegisterMap(map[string]interface{}{"named": func() {}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var counts []int
+	var infos []chain.FuncInfo
+	if err = c.Walk(func(p chain.Predicate, fns []chain.FuncInfo) error {
+		counts = append(counts, len(fns))
+		infos = append(infos, fns...)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 2 || counts[0] != 2 || counts[1] != 1 {
+		t.Fatalf("unexpected node layout %v", counts)
+	}
+	if !strings.HasSuffix(infos[0].Symbol, ".walkFunc") || !strings.Contains(infos[0].Site, "walk_test.go:") {
+		t.Fatalf("unexpected func info %+v", infos[0])
+	}
+	if infos[1].Name != "named" {
+		t.Fatalf("expected registration name, got %+v", infos[1])
+	}
+
+	stop := errors.New("stop")
+	visited := 0
+	if err = c.Walk(func(chain.Predicate, []chain.FuncInfo) error {
+		visited++
+		return stop
+	}); err != stop || visited != 1 {
+		t.Fatalf("walk did not stop: %v after %d nodes", err, visited)
+	}
+}
+
+func TestFuncs(t *testing.T) {
+	c := chain.New()
+	pred, _ := c.Register(walkFunc)
+	pred.After(func() {})
+	funcs := c.Funcs()
+	if len(funcs) != 2 || funcs[0].Node != 0 || funcs[1].Node != 1 {
+		t.Fatalf("unexpected funcs %+v", funcs)
+	}
+	if !strings.HasSuffix(funcs[0].Symbol, ".walkFunc") || funcs[1].Type.NumIn() != 0 {
+		t.Fatalf("unexpected func info %+v", funcs[0])
+	}
+}